@@ -0,0 +1,262 @@
+package main
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+
+	"github.com/aaronjanse/3mux/ecma48"
+	"github.com/aaronjanse/3mux/render"
+)
+
+// A PasteBuffer is text saved for pasting, named automatically (e.g. buffer3) unless given a name
+type PasteBuffer struct {
+	name      string
+	text      string
+	automatic bool
+}
+
+// pasteBuffers is a stack of buffers, most recent first
+var pasteBuffers []*PasteBuffer
+
+// bufferCounter numbers automatically named buffers
+var bufferCounter int
+
+// bufferLimit is how many automatically named buffers are kept before the oldest is dropped
+const bufferLimit = 50
+
+// addBuffer pushes text yanked in copy mode or copied by a program onto the stack
+func addBuffer(text string) {
+	pasteBuffers = append([]*PasteBuffer{{
+		name:      fmt.Sprintf("buffer%d", bufferCounter),
+		text:      text,
+		automatic: true,
+	}}, pasteBuffers...)
+	bufferCounter++
+
+	automatic := 0
+	for idx := 0; idx < len(pasteBuffers); idx++ {
+		if pasteBuffers[idx].automatic {
+			automatic++
+			if automatic > bufferLimit {
+				deleteBuffer(idx)
+				idx--
+			}
+		}
+	}
+}
+
+// setBuffer replaces the text of the named buffer, creating it if needed, and moves it to the top of the stack
+func setBuffer(name, text string) {
+	idx := findBuffer(name)
+	pasteBuffers = append([]*PasteBuffer{{name: name, text: text}}, pasteBuffers...)
+	if idx != -1 {
+		// the old one is deleted last so the chooser never sees the stack empty
+		deleteBuffer(idx + 1)
+	}
+}
+
+// findBuffer returns the index of the named buffer, or -1 if there is none.
+// An empty name refers to the most recent buffer
+func findBuffer(name string) int {
+	if name == "" && len(pasteBuffers) > 0 {
+		return 0
+	}
+	for idx, b := range pasteBuffers {
+		if b.name == name {
+			return idx
+		}
+	}
+	return -1
+}
+
+// deleteBuffer removes a buffer from the stack. If the chooser is open, its selection is kept on a buffer,
+// and it's closed once there are none left
+func deleteBuffer(idx int) {
+	pasteBuffers = append(pasteBuffers[:idx], pasteBuffers[idx+1:]...)
+
+	if bufferChooser != nil {
+		if len(pasteBuffers) == 0 {
+			closeBufferChooser()
+		} else if bufferChooser.selectionIdx >= len(pasteBuffers) {
+			bufferChooser.selectionIdx = len(pasteBuffers) - 1
+		}
+	}
+}
+
+// getBufferFromString finds a buffer by name, or the most recent buffer if name is empty
+func getBufferFromString(name string) (*PasteBuffer, error) {
+	idx := findBuffer(name)
+	if idx == -1 {
+		if name == "" {
+			return nil, fmt.Errorf("no paste buffers")
+		}
+		return nil, fmt.Errorf("no buffer named %s", name)
+	}
+	return pasteBuffers[idx], nil
+}
+
+// paste types the most recent paste buffer into the focused pane
+func paste() {
+	if len(pasteBuffers) > 0 {
+		pasteText(pasteBuffers[0].text)
+	}
+}
+
+// pasteText types text into the focused pane, leaving copy mode first
+func pasteText(text string) {
+	pane := root.workspaces[root.selectionIdx].focusedPane()
+	if pane.copyMode != nil {
+		pane.exitCopyMode()
+	}
+	if !pane.searchMode {
+		// terminals send carriage returns for newlines
+		pane.paste(strings.Replace(text, "\n", "\r", -1))
+	}
+}
+
+// handlePaste passes along text pasted into the host terminal as it was sent.
+// In search mode it is typed into the query instead
+func handlePaste(text string) {
+	pane := root.workspaces[root.selectionIdx].focusedPane()
+	if pane.copyMode != nil {
+		pane.exitCopyMode()
+	}
+	if pane.searchMode {
+		pane.handleStdin(text)
+	} else {
+		pane.paste(text)
+	}
+}
+
+// paste writes text to the pane's program as if it were pasted into a terminal.
+// Programs that enabled bracketed paste get it between ESC[200~ and ESC[201~ so they don't run it as typed commands
+func (t *Pane) paste(text string) {
+	if t.exited {
+		return
+	}
+
+	if t.vterm.BracketedPaste {
+		// the pasted text must not be able to end the paste early
+		text = "\x1b[200~" + strings.Replace(text, "\x1b[201~", "", -1) + "\x1b[201~"
+	}
+
+	t.vterm.ScrollbackReset()
+	if _, err := t.ptmx.Write([]byte(text)); err != nil {
+		fatalShutdownNow("writing to shell stdin: " + err.Error())
+	}
+	t.vterm.RefreshCursor()
+}
+
+// BufferChooser is the state of the overlay listing the paste buffers, see chooseBuffer
+type BufferChooser struct {
+	selectionIdx int
+}
+
+// bufferChooser is set while the overlay is open. It takes all keyboard input
+var bufferChooser *BufferChooser
+
+// chooseBuffer opens an overlay to pick a paste buffer to paste or delete
+func chooseBuffer() {
+	if len(pasteBuffers) == 0 {
+		statusError = "no paste buffers"
+		return
+	}
+
+	bufferChooser = &BufferChooser{}
+	root.refreshRenderRect()
+}
+
+func closeBufferChooser() {
+	bufferChooser = nil
+	root.refreshRenderRect()
+}
+
+// handleBufferChooserInput moves through the buffers with j/k, pastes one with Enter, or deletes one with d.
+// Any other key closes the overlay
+func handleBufferChooserInput(obj ecma48.Output) {
+	c := bufferChooser
+
+	key := string(obj.Raw)
+	if x, ok := obj.Parsed.(ecma48.CursorMovement); ok {
+		switch x.Direction {
+		case ecma48.Up:
+			key = "k"
+		case ecma48.Down:
+			key = "j"
+		}
+	}
+
+	switch key {
+	case "j":
+		if c.selectionIdx < len(pasteBuffers)-1 {
+			c.selectionIdx++
+		}
+	case "k":
+		if c.selectionIdx > 0 {
+			c.selectionIdx--
+		}
+	case "\r", "\n":
+		text := pasteBuffers[c.selectionIdx].text
+		closeBufferChooser()
+		pasteText(text)
+		return
+	case "d":
+		deleteBuffer(c.selectionIdx)
+		if bufferChooser == nil {
+			return
+		}
+	default:
+		closeBufferChooser()
+		return
+	}
+
+	root.refreshRenderRect()
+}
+
+// bufferChooserRect is where the overlay goes in a workspace's area, not including its border
+func bufferChooserRect(area Rect) Rect {
+	w := area.w * 3 / 4
+	h := len(pasteBuffers)
+	if h > area.h-2 {
+		h = area.h - 2
+	}
+	return Rect{x: area.x + (area.w-w)/2, y: area.y + (area.h-h)/2, w: w, h: h}
+}
+
+// drawBufferChooser lists the paste buffers in r, scrolled to keep the selected one in view
+func drawBufferChooser(r Rect, layer int) {
+	drawBorder(r, selectionStyle, layer)
+
+	top := 0
+	if bufferChooser.selectionIdx >= r.h {
+		top = bufferChooser.selectionIdx - r.h + 1
+	}
+
+	for row := 0; row < r.h; row++ {
+		idx := top + row
+		line := []rune{}
+		if idx < len(pasteBuffers) {
+			b := pasteBuffers[idx]
+			line = []rune(fmt.Sprintf(" %s: %s", b.name, strconv.Quote(b.text)))
+		}
+
+		style := render.Style{}
+		if idx == bufferChooser.selectionIdx {
+			style.Reverse = true
+		}
+
+		for i := 0; i < r.w; i++ {
+			ch := ' '
+			if i < len(line) {
+				ch = line[i]
+			}
+			renderer.HandleLayeredCh(render.PositionedChar{
+				Rune: ch,
+				Cursor: render.Cursor{
+					X: r.x + i, Y: r.y + row, Style: style,
+				},
+			}, layer)
+		}
+	}
+}
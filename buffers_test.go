@@ -0,0 +1,55 @@
+package main
+
+import "testing"
+
+func TestBufferChooserSelectionStaysOnABuffer(t *testing.T) {
+	defer func() {
+		pasteBuffers = nil
+		bufferChooser = nil
+	}()
+
+	pasteBuffers = nil
+	setBuffer("a", "1")
+	setBuffer("b", "2")
+	setBuffer("c", "3")
+	bufferChooser = &BufferChooser{selectionIdx: 2}
+
+	// e.g. `3mux msg 'deleteBuffer(a)'` while the chooser is open
+	deleteBuffer(findBuffer("a"))
+	if bufferChooser.selectionIdx != 1 {
+		t.Fatalf("after deleting the selected buffer at the bottom, selectionIdx = %d, want 1", bufferChooser.selectionIdx)
+	}
+
+	deleteBuffer(findBuffer("c"))
+	if bufferChooser.selectionIdx != 0 {
+		t.Fatalf("after deleting a buffer above the selection, selectionIdx = %d, want 0", bufferChooser.selectionIdx)
+	}
+
+	// replacing the last buffer doesn't empty the stack along the way
+	setBuffer("b", "4")
+	if bufferChooser == nil || bufferChooser.selectionIdx != 0 {
+		t.Fatalf("after replacing the last buffer, bufferChooser = %+v", bufferChooser)
+	}
+	if len(pasteBuffers) != 1 || pasteBuffers[0].text != "4" {
+		t.Fatalf("after replacing the last buffer, got %d buffers", len(pasteBuffers))
+	}
+}
+
+func TestAddBufferDropsOldestAutomaticBuffers(t *testing.T) {
+	defer func() {
+		pasteBuffers = nil
+	}()
+
+	pasteBuffers = nil
+	setBuffer("kept", "named buffers are never dropped")
+	for i := 0; i < bufferLimit+5; i++ {
+		addBuffer("text")
+	}
+
+	if len(pasteBuffers) != bufferLimit+1 {
+		t.Errorf("got %d buffers, want %d", len(pasteBuffers), bufferLimit+1)
+	}
+	if findBuffer("kept") == -1 {
+		t.Errorf("the named buffer was dropped")
+	}
+}
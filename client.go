@@ -0,0 +1,190 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"os"
+	"os/exec"
+	"os/signal"
+	"sync"
+	"syscall"
+	"time"
+
+	"golang.org/x/crypto/ssh/terminal"
+)
+
+// startServer launches a detached server process for the session which outlives the current terminal
+func startServer(session string) error {
+	// the server can't show errors, so catch config mistakes while we still have a terminal
+	if _, err := loadConfig(); err != nil {
+		return err
+	}
+	if _, err := startingLayout(); err != nil {
+		return err
+	}
+
+	exe, err := os.Executable()
+	if err != nil {
+		return err
+	}
+
+	args := []string{"-server", session}
+	if *writeLogs {
+		args = append(args, "-log")
+	}
+	if *cpuprofile != "" {
+		args = append(args, "-cpuprofile", *cpuprofile)
+	}
+	if *restoreFile != "" {
+		args = append(args, "-restore", *restoreFile)
+	}
+	if *layoutPath != "" {
+		args = append(args, "-l", *layoutPath)
+	}
+
+	cmd := exec.Command(exe, args...)
+	cmd.SysProcAttr = &syscall.SysProcAttr{Setsid: true}
+	if err := cmd.Start(); err != nil {
+		return err
+	}
+	return cmd.Process.Release()
+}
+
+// connect dials the session's server, starting one first if create is set and none is running
+func connect(session string, create bool) (net.Conn, error) {
+	path := socketPath(session)
+	if conn, err := net.Dial("unix", path); err == nil {
+		if *restoreFile != "" || *layoutPath != "" {
+			conn.Close()
+			return nil, fmt.Errorf("can't load a layout into running session %q", session)
+		}
+		return conn, nil
+	}
+
+	if !create {
+		return nil, fmt.Errorf("no session named %q", session)
+	}
+
+	if err := startServer(session); err != nil {
+		return nil, err
+	}
+
+	// wait for the server to start listening
+	for i := 0; i < 200; i++ {
+		if conn, err := net.Dial("unix", path); err == nil {
+			return conn, nil
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+
+	return nil, errors.New("timed out waiting for server to start")
+}
+
+// request sends a single message to the session's server and returns its reply
+func request(session string, t msgType, payload []byte) (string, error) {
+	conn, err := connect(session, false)
+	if err != nil {
+		return "", err
+	}
+	defer conn.Close()
+
+	if err := writeMsg(conn, t, payload); err != nil {
+		return "", err
+	}
+
+	replyType, reply, err := readMsg(conn)
+	if err == io.EOF {
+		return "", errors.New("lost server")
+	} else if err != nil {
+		return "", err
+	}
+
+	switch replyType {
+	case msgReply:
+		return string(reply), nil
+	case msgError:
+		return "", errors.New(string(reply))
+	default:
+		return "", fmt.Errorf("unexpected reply from server: %d", replyType)
+	}
+}
+
+// attach is a blocking function that connects the host terminal to the server.
+// It returns once the client detaches or the server exits, along with text to show the user.
+func attach(conn net.Conn) (string, error) {
+	defer conn.Close()
+
+	w, h, err := GetTermSize()
+	if err != nil {
+		return "", fmt.Errorf("while getting terminal size: %s", err.Error())
+	}
+
+	oldState, err = terminal.MakeRaw(0)
+	if err != nil {
+		return "", err
+	}
+
+	fmt.Print("\x1b[?1049h")
+	fmt.Print("\x1b[?1006h")
+	fmt.Print("\x1b[?1002h")
+	fmt.Print("\x1b[?2004h")
+	fmt.Print("\x1b[?1l")
+
+	defer Shutdown()
+
+	// stdin and SIGWINCH are handled by separate goroutines
+	var writeMutex sync.Mutex
+	send := func(t msgType, payload []byte) {
+		writeMutex.Lock()
+		writeMsg(conn, t, payload)
+		writeMutex.Unlock()
+	}
+
+	if err := writeMsg(conn, msgAttach, encodeSize(w, h)); err != nil {
+		return "", err
+	}
+
+	go func() {
+		c := make(chan os.Signal, 1)
+		signal.Notify(c, syscall.SIGWINCH)
+		for range c {
+			w, h, err := GetTermSize()
+			if err != nil {
+				continue
+			}
+			send(msgResize, encodeSize(w, h))
+		}
+	}()
+
+	go func() {
+		buf := make([]byte, 4096)
+		for {
+			n, err := os.Stdin.Read(buf)
+			if err != nil {
+				return
+			}
+			send(msgInput, buf[:n])
+		}
+	}()
+
+	for {
+		t, payload, err := readMsg(conn)
+		if err != nil {
+			return "[lost server]", nil
+		}
+
+		switch t {
+		case msgOutput:
+			os.Stdout.Write(payload)
+		case msgDetach:
+			return "[detached]", nil
+		case msgExit:
+			if len(payload) > 0 {
+				return string(payload), nil
+			}
+			return "[exited]", nil
+		}
+	}
+}
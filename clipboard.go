@@ -0,0 +1,90 @@
+package main
+
+import (
+	"encoding/base64"
+	"fmt"
+	"log"
+	"strings"
+
+	"github.com/aaronjanse/3mux/ecma48"
+)
+
+// A ClipboardPolicy decides what happens when a program copies text with OSC 52
+type ClipboardPolicy int
+
+// clipboard policies. A pane's policy is ClipboardDefault until set with the clipboard operation
+const (
+	ClipboardDefault ClipboardPolicy = iota // use the config file's policy
+	ClipboardAllow                          // copy the text
+	ClipboardDeny                           // drop the text
+	ClipboardAsk                            // prompt in the status bar first
+)
+
+func getClipboardPolicyFromString(s string) (ClipboardPolicy, error) {
+	switch s {
+	case "Default":
+		return ClipboardDefault, nil
+	case "Allow":
+		return ClipboardAllow, nil
+	case "Deny":
+		return ClipboardDeny, nil
+	case "Ask":
+		return ClipboardAsk, nil
+	default:
+		return 0, fmt.Errorf("invalid clipboard policy: %v", s)
+	}
+}
+
+// clipboardRequest is text copied by a pane with the Ask policy, waiting for the user to allow it
+type clipboardRequest struct {
+	pane *Pane
+	text string
+}
+
+// pendingClipboard is answered by the next keypress, while the mouse keeps working. A newer request replaces an unanswered one
+var pendingClipboard *clipboardRequest
+
+// yank pushes text onto the paste buffers and sends it on to the host terminal's clipboard
+func yank(text string) {
+	addBuffer(text)
+	renderer.Passthrough(fmt.Sprintf("\x1b]52;c;%s\x07", base64.StdEncoding.EncodeToString([]byte(text))))
+}
+
+// setClipboard handles text the pane's program copied with OSC 52, following the pane's clipboard policy
+func (t *Pane) setClipboard(text string) {
+	policy := t.clipboard
+	if policy == ClipboardDefault {
+		policy = config.clipboard
+	}
+
+	switch policy {
+	case ClipboardAllow:
+		yank(text)
+	case ClipboardDeny:
+		log.Printf("denied clipboard from pane %%%d", t.id)
+	case ClipboardAsk:
+		pendingClipboard = &clipboardRequest{pane: t, text: text}
+		refreshStatusBar()
+	}
+}
+
+// clipboardPrompt is shown in place of the status bar while a clipboard request is pending
+func clipboardPrompt() string {
+	return fmt.Sprintf("%%%d wants to copy %d characters to the clipboard. Allow? (y/n)",
+		pendingClipboard.pane.id, len([]rune(pendingClipboard.text)))
+}
+
+// answerClipboardRequest copies the pending text if the key pressed was `y`. Any other key drops it
+func answerClipboardRequest(obj ecma48.Output) {
+	if strings.ToLower(string(obj.Raw)) == "y" {
+		yank(pendingClipboard.text)
+	} else {
+		log.Printf("denied clipboard from pane %%%d", pendingClipboard.pane.id)
+	}
+	pendingClipboard = nil
+
+	if !config.statusBar {
+		// the prompt was drawn over the bottom of the panes
+		root.refreshRenderRect()
+	}
+}
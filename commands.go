@@ -0,0 +1,327 @@
+package main
+
+import (
+	"errors"
+	"flag"
+	"fmt"
+	"io/ioutil"
+	"net"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+const defaultSession = "default"
+
+// runCommand handles the subcommands given after the global flags, e.g. `3mux ls`
+func runCommand(args []string) error {
+	if len(args) == 0 {
+		return attachSession(defaultSession, true)
+	}
+
+	switch args[0] {
+	case "new", "new-session":
+		fs := flag.NewFlagSet("new", flag.ExitOnError)
+		name := fs.String("s", "", "session name")
+		fs.Parse(args[1:])
+
+		if *name == "" {
+			*name = unusedSessionName()
+		} else if err := validateSessionName(*name); err != nil {
+			return err
+		}
+		if sessionExists(*name) {
+			return fmt.Errorf("duplicate session: %s", *name)
+		}
+		return attachSession(*name, true)
+	case "attach", "attach-session", "a":
+		fs := flag.NewFlagSet("attach", flag.ExitOnError)
+		target := fs.String("t", defaultSession, "session to attach to")
+		fs.Parse(args[1:])
+
+		return attachSession(*target, false)
+	case "ls", "list-sessions":
+		names, err := listSessions()
+		if err != nil {
+			return err
+		}
+		if len(names) == 0 {
+			return fmt.Errorf("no sessions")
+		}
+		for _, name := range names {
+			info, err := request(name, msgInfo, nil)
+			if err != nil {
+				continue
+			}
+			fmt.Println(info)
+		}
+		return nil
+	case "kill-session":
+		fs := flag.NewFlagSet("kill-session", flag.ExitOnError)
+		target := fs.String("t", defaultSession, "session to kill")
+		fs.Parse(args[1:])
+
+		_, err := request(*target, msgKill, nil)
+		return err
+	case "get-tree":
+		fs := flag.NewFlagSet("get-tree", flag.ExitOnError)
+		target := fs.String("t", defaultSession, "session to describe")
+		fs.Parse(args[1:])
+
+		tree, err := request(*target, msgTree, nil)
+		if err != nil {
+			return err
+		}
+		fmt.Println(tree)
+		return nil
+	case "save-layout":
+		fs := flag.NewFlagSet("save-layout", flag.ExitOnError)
+		target := fs.String("t", defaultSession, "session to save")
+		fs.Parse(args[1:])
+
+		if fs.NArg() == 0 {
+			return runOperation(*target, "saveLayout")
+		}
+
+		// the server runs from a different directory
+		path, err := filepath.Abs(fs.Arg(0))
+		if err != nil {
+			return err
+		}
+		return runOperation(*target, fmt.Sprintf("saveLayout(%s)", strconv.Quote(path)))
+	case "save-buffer":
+		fs := flag.NewFlagSet("save-buffer", flag.ExitOnError)
+		target := fs.String("t", defaultSession, "session to read from")
+		name := fs.String("b", "", "name of the buffer (default: the most recent)")
+		fs.Parse(args[1:])
+
+		text, err := request(*target, msgBuffer, []byte(*name))
+		if err != nil {
+			return err
+		}
+		if fs.NArg() == 0 || fs.Arg(0) == "-" {
+			_, err = os.Stdout.WriteString(text)
+			return err
+		}
+		return ioutil.WriteFile(fs.Arg(0), []byte(text), 0644)
+	case "load-buffer":
+		fs := flag.NewFlagSet("load-buffer", flag.ExitOnError)
+		target := fs.String("t", defaultSession, "session to control")
+		name := fs.String("b", "", "name of the buffer (default: a new buffer)")
+		fs.Parse(args[1:])
+
+		var text []byte
+		var err error
+		if fs.NArg() == 0 || fs.Arg(0) == "-" {
+			text, err = ioutil.ReadAll(os.Stdin)
+		} else {
+			text, err = ioutil.ReadFile(fs.Arg(0))
+		}
+		if err != nil {
+			return err
+		}
+
+		if *name == "" {
+			return runOperation(*target, fmt.Sprintf("setBuffer(%s)", strconv.Quote(string(text))))
+		}
+		return runOperation(*target, fmt.Sprintf("setBuffer(%s, %s)", strconv.Quote(*name), strconv.Quote(string(text))))
+	case "msg":
+		fs := flag.NewFlagSet("msg", flag.ExitOnError)
+		target := fs.String("t", defaultSession, "session to control")
+		fs.Parse(args[1:])
+
+		if fs.NArg() == 0 {
+			return errors.New("usage: 3mux msg [-t session] <operation>")
+		}
+		return runOperation(*target, strings.Join(fs.Args(), " "))
+	case "new-pane", "new-window":
+		fs := flag.NewFlagSet("new-pane", flag.ExitOnError)
+		target := fs.String("t", defaultSession, "session to control")
+		dir := fs.String("c", "", "working directory of the new pane")
+		env := stringList{}
+		fs.Var(&env, "e", "environment variable like `KEY=value` (may be repeated)")
+		fs.Parse(args[1:])
+
+		params := []string{}
+		if *dir != "" {
+			// the server runs from a different directory
+			path, err := filepath.Abs(*dir)
+			if err != nil {
+				return err
+			}
+			params = append(params, strconv.Quote("cwd="+path))
+		}
+		for _, variable := range env {
+			params = append(params, strconv.Quote("env="+variable))
+		}
+		for _, arg := range fs.Args() {
+			params = append(params, strconv.Quote(arg))
+		}
+
+		if len(params) == 0 {
+			return runOperation(*target, "newWindow")
+		}
+		return runOperation(*target, fmt.Sprintf("newWindow(%s)", strings.Join(params, ", ")))
+	case "split", "split-window":
+		fs := flag.NewFlagSet("split", flag.ExitOnError)
+		target := fs.String("t", defaultSession, "session to control")
+		vertical := fs.Bool("v", false, "put the new pane below instead of to the right")
+		fs.Parse(args[1:])
+
+		if *vertical {
+			return runOperation(*target, "split(Vertical)")
+		}
+		return runOperation(*target, "split(Horizontal)")
+	case "focus", "select-pane":
+		fs := flag.NewFlagSet("focus", flag.ExitOnError)
+		target := fs.String("t", defaultSession, "session to control")
+		pane := fs.String("p", "", "id of the pane to focus")
+		fs.Parse(args[1:])
+
+		if *pane != "" {
+			return runOperation(*target, fmt.Sprintf("focusPane(%s)", *pane))
+		} else if fs.NArg() == 1 {
+			return runOperation(*target, fmt.Sprintf("moveSelection(%s)", fs.Arg(0)))
+		}
+		return errors.New("usage: 3mux focus [-t session] (-p pane | Up | Down | Left | Right)")
+	case "kill-pane":
+		fs := flag.NewFlagSet("kill-pane", flag.ExitOnError)
+		target := fs.String("t", defaultSession, "session to control")
+		pane := fs.String("p", "", "id of the pane to kill (default: the focused pane)")
+		fs.Parse(args[1:])
+
+		if *pane != "" {
+			return runOperation(*target, fmt.Sprintf("killPane(%s)", *pane))
+		}
+		return runOperation(*target, "killWindow")
+	case "send-keys":
+		fs := flag.NewFlagSet("send-keys", flag.ExitOnError)
+		target := fs.String("t", defaultSession, "session to control")
+		pane := fs.String("p", "", "id of the pane to type into (default: the focused pane)")
+		fs.Parse(args[1:])
+
+		text := strconv.Quote(keysToText(fs.Args()))
+		if *pane != "" {
+			return runOperation(*target, fmt.Sprintf("sendKeys(%s, %s)", *pane, text))
+		}
+		return runOperation(*target, fmt.Sprintf("sendKeys(%s)", text))
+	default:
+		return fmt.Errorf("unknown command: %s", args[0])
+	}
+}
+
+func attachSession(name string, create bool) error {
+	conn, err := connect(name, create)
+	if err != nil {
+		return err
+	}
+
+	msg, err := attach(conn)
+	if err != nil {
+		return err
+	}
+	fmt.Println(msg)
+	return nil
+}
+
+// stringList is a flag that may be given more than once
+type stringList []string
+
+func (l *stringList) String() string {
+	return strings.Join(*l, ", ")
+}
+
+func (l *stringList) Set(value string) error {
+	*l = append(*l, value)
+	return nil
+}
+
+// runOperation asks the session's server to run an operation, e.g. `moveSelection(Left)`
+func runOperation(session, op string) error {
+	_, err := request(session, msgCommand, []byte(op))
+	return err
+}
+
+// keyNames are the special keys understood by `3mux send-keys`
+var keyNames = map[string]string{
+	"Enter":  "\r",
+	"Tab":    "\t",
+	"Escape": "\x1b",
+	"Space":  " ",
+	"BSpace": "\x7f",
+	"Up":     "\x1bOA",
+	"Down":   "\x1bOB",
+	"Right":  "\x1bOC",
+	"Left":   "\x1bOD",
+}
+
+// keysToText joins the arguments of `3mux send-keys`, translating key names like Enter and C-c.
+// Anything else is typed as-is.
+func keysToText(keys []string) string {
+	var text strings.Builder
+	for _, key := range keys {
+		if seq, ok := keyNames[key]; ok {
+			text.WriteString(seq)
+		} else if len(key) == 3 && strings.HasPrefix(key, "C-") {
+			text.WriteByte(key[2] & 0x1f)
+		} else {
+			text.WriteString(key)
+		}
+	}
+	return text.String()
+}
+
+// listSessions returns the names of running sessions, removing sockets left behind by dead servers
+func listSessions() ([]string, error) {
+	entries, err := ioutil.ReadDir(socketDir())
+	if os.IsNotExist(err) {
+		return []string{}, nil
+	} else if err != nil {
+		return nil, err
+	}
+
+	names := []string{}
+	for _, entry := range entries {
+		if entry.Mode()&os.ModeSocket == 0 {
+			continue
+		}
+
+		name := entry.Name()
+		if sessionExists(name) {
+			names = append(names, name)
+		} else {
+			os.Remove(socketPath(name))
+		}
+	}
+
+	sort.Strings(names)
+	return names, nil
+}
+
+func sessionExists(name string) bool {
+	conn, err := net.Dial("unix", socketPath(name))
+	if err != nil {
+		return false
+	}
+	conn.Close()
+	return true
+}
+
+// unusedSessionName picks the lowest number not already used as a session name
+func unusedSessionName() string {
+	for i := 0; ; i++ {
+		name := strconv.Itoa(i)
+		if !sessionExists(name) {
+			return name
+		}
+	}
+}
+
+func validateSessionName(name string) error {
+	if strings.ContainsAny(name, "/\x00") || name == "." || name == ".." {
+		return fmt.Errorf("invalid session name: %q", name)
+	}
+	return nil
+}
@@ -1,46 +1,46 @@
 package main
 
 import (
+	"fmt"
 	"log"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strconv"
 	"strings"
+	"unicode"
+
+	"github.com/BurntSushi/toml"
 )
 
 // Config stores all user configuration values
 type Config struct {
-	statusBar bool
-	bindings  map[string]func()
+	statusBar    bool
+	remainOnExit bool            // keep panes on screen after their command exits
+	clipboard    ClipboardPolicy // what happens when a program copies text with OSC 52
+	bindings     map[string]func()
 }
 
 var configFuncBindings = map[string]func(){
 	"newWindow": func() {
-		if !root.workspaces[root.selectionIdx].doFullscreen {
-			root.AddPane()
-			root.simplify()
-			root.refreshRenderRect()
-		}
+		root.AddPane()
+		root.simplify()
+		root.refreshRenderRect()
 	},
 	"killWindow": func() {
-		if root.workspaces[root.selectionIdx].doFullscreen {
-			unfullscreen()
-		}
 		killWindow()
 		root.simplify()
 		root.refreshRenderRect()
 	},
+	"copyMode":     enterCopyMode,
+	"paste":        paste,
+	"chooseBuffer": chooseBuffer,
 	"resize": func() {
-		if !root.workspaces[root.selectionIdx].doFullscreen {
-			resizeMode = true
-		}
-	},
-	"fullscreen": func() {
-		if root.workspaces[root.selectionIdx].doFullscreen {
-			unfullscreen()
-		} else {
-			fullscreen()
-		}
-		root.simplify()
-		root.refreshRenderRect()
+		unzoom()
+		resizeMode = true
 	},
+	"fullscreen": toggleZoom,
+	"zoom":       toggleZoom,
 	"debugSlowMode": func() {
 		log.Println("slowmo enabled!")
 		if getSelection().getContainer().(*Pane).vterm.DebugSlowMode {
@@ -60,89 +60,434 @@ var configFuncBindings = map[string]func(){
 		}
 	},
 	"search": search,
+	"detach": detach,
 	"moveWindowUp": func() {
-		if !root.workspaces[root.selectionIdx].doFullscreen {
-			moveWindow(Up)
-			root.simplify()
-			root.refreshRenderRect()
-		}
+		moveWindow(Up)
+		root.simplify()
+		root.refreshRenderRect()
 	},
 	"moveWindowDown": func() {
-		if !root.workspaces[root.selectionIdx].doFullscreen {
-			moveWindow(Down)
-			root.simplify()
-			root.refreshRenderRect()
-		}
+		moveWindow(Down)
+		root.simplify()
+		root.refreshRenderRect()
 	},
 	"moveWindowLeft": func() {
-		if !root.workspaces[root.selectionIdx].doFullscreen {
-			moveWindow(Left)
-			root.simplify()
-			root.refreshRenderRect()
-		}
+		moveWindow(Left)
+		root.simplify()
+		root.refreshRenderRect()
 	},
 	"moveWindowRight": func() {
-		if !root.workspaces[root.selectionIdx].doFullscreen {
-			moveWindow(Right)
-		}
+		moveWindow(Right)
+	},
+	"layoutSplit": func() {
+		setSplitMode(Tiled)
+	},
+	"layoutTabbed": func() {
+		setSplitMode(Tabbed)
+	},
+	"layoutStacked": func() {
+		setSplitMode(Stacked)
+	},
+	"splitHorizontal": func() {
+		splitNext(false)
+	},
+	"splitVertical": func() {
+		splitNext(true)
+	},
+	"focusParent": func() {
+		focusParent()
+	},
+	"focusChild": func() {
+		focusChild()
+	},
+	"nextLayout": func() {
+		nextLayout()
+	},
+	"equalize": func() {
+		equalize()
+	},
+	"toggleFloating": func() {
+		toggleFloating()
+	},
+	"focusFloating": func() {
+		focusFloating()
+	},
+	"moveToScratchpad": func() {
+		moveToScratchpad()
+	},
+	"showScratchpad": func() {
+		showScratchpad()
 	},
 	"moveSelectionUp": func() {
-		if !root.workspaces[root.selectionIdx].doFullscreen {
-			moveSelection(Up)
-		}
+		moveSelection(Up)
 	},
 	"moveSelectionDown": func() {
-		if !root.workspaces[root.selectionIdx].doFullscreen {
-			moveSelection(Down)
-		}
+		moveSelection(Down)
 	},
 	"moveSelectionLeft": func() {
-		if !root.workspaces[root.selectionIdx].doFullscreen {
-			moveSelection(Left)
-		}
+		moveSelection(Left)
 	},
 	"moveSelectionRight": func() {
-		if !root.workspaces[root.selectionIdx].doFullscreen {
-			moveSelection(Right)
-		}
+		moveSelection(Right)
 	},
 }
 
-func compileBindings(sourceBindings map[string][]string) map[string]func() {
-	compiledBindings := map[string]func(){}
-	for funcName, keyCodes := range sourceBindings {
-		fn := configFuncBindings[funcName]
+// compileBindings adds the functions for each operation's key codes to compiledBindings
+func compileBindings(sourceBindings map[string][]string, compiledBindings map[string]func()) error {
+	for op, keyCodes := range sourceBindings {
+		fn, err := compileOperation(op)
+		if err != nil {
+			return err
+		}
 		for _, keyCode := range keyCodes {
-			compiledBindings[keyCode] = fn
+			human, err := normalizeKeyCode(keyCode)
+			if err != nil {
+				return fmt.Errorf("binding for %s: %s", op, err.Error())
+			}
+			compiledBindings[human] = fn
 		}
 	}
 
-	return compiledBindings
+	return nil
+}
+
+// compileOperation turns an operation such as `newWindow` or `moveWindow(Up)` into a function.
+// Operations are validated up front so that a bad binding is reported at startup rather than when pressed.
+func compileOperation(op string) (func(), error) {
+	if fn, ok := configFuncBindings[op]; ok {
+		return fn, nil
+	}
+
+	funcName, params, err := parseOperationCode(op)
+	if err != nil {
+		return nil, err
+	}
+	switch funcName {
+	case "moveWindow", "moveSelection", "resizeWindow":
+		if _, err := getDirectionFromString(params[0]); err != nil {
+			return nil, fmt.Errorf("%s: %s", op, err.Error())
+		}
+	case "workspace", "moveToWorkspace":
+		if _, err := getWorkspaceFromString(params[0]); err != nil {
+			return nil, fmt.Errorf("%s: %s", op, err.Error())
+		}
+	case "split":
+		if _, err := getOrientationFromString(params[0]); err != nil {
+			return nil, fmt.Errorf("%s: %s", op, err.Error())
+		}
+	case "layout":
+		if _, err := getSplitModeFromString(params[0]); err != nil {
+			return nil, fmt.Errorf("%s: %s", op, err.Error())
+		}
+	case "selectLayout":
+		if _, err := getPresetFromString(params[0]); err != nil {
+			return nil, fmt.Errorf("%s: %s", op, err.Error())
+		}
+	case "focusPane", "killPane", "swapPane":
+		if _, err := getPaneIDFromString(params[0]); err != nil {
+			return nil, fmt.Errorf("%s: %s", op, err.Error())
+		}
+	case "respawnPane", "mark":
+		if params[0] == "" {
+			break
+		}
+		if _, err := getPaneIDFromString(params[0]); err != nil {
+			return nil, fmt.Errorf("%s: %s", op, err.Error())
+		}
+	case "clipboard":
+		if len(params) > 2 {
+			return nil, fmt.Errorf("%s: too many parameters", op)
+		} else if len(params) == 2 {
+			if _, err := getPaneIDFromString(params[0]); err != nil {
+				return nil, fmt.Errorf("%s: %s", op, err.Error())
+			}
+		}
+		if _, err := getClipboardPolicyFromString(params[len(params)-1]); err != nil {
+			return nil, fmt.Errorf("%s: %s", op, err.Error())
+		}
+	case "pasteBuffer", "deleteBuffer":
+		if params[0] == "" {
+			return nil, fmt.Errorf("%s: missing buffer name", op)
+		}
+	case "setBuffer":
+		if len(params) > 2 {
+			return nil, fmt.Errorf("%s: too many parameters", op)
+		}
+	case "saveLayout":
+		// any path is fine
+	case "swapWithMark":
+		// nothing to check
+	case "rotate":
+		if params[0] != "" && params[0] != "Reverse" {
+			return nil, fmt.Errorf("%s: invalid direction: %s", op, params[0])
+		}
+	case "newWindow":
+		if _, _, _, err := parseNewWindowParams(params); err != nil {
+			return nil, fmt.Errorf("%s: %s", op, err.Error())
+		}
+	case "sendKeys":
+		if len(params) > 2 {
+			return nil, fmt.Errorf("%s: too many parameters", op)
+		} else if len(params) == 2 {
+			if _, err := getPaneIDFromString(params[0]); err != nil {
+				return nil, fmt.Errorf("%s: %s", op, err.Error())
+			}
+		}
+	default:
+		if _, ok := configFuncBindings[funcName]; !ok {
+			return nil, fmt.Errorf("unknown operation: %s", op)
+		}
+	}
+
+	return func() {
+		if err := handleCommand(op); err != nil {
+			log.Println(err.Error())
+			statusError = err.Error()
+			refreshStatusBar()
+		}
+	}, nil
+}
+
+// shiftedKeys are the characters typed with Shift on a US keyboard. Terminals send Alt+Shift+1 as Alt+!
+var shiftedKeys = map[rune]rune{
+	'1': '!', '2': '@', '3': '#', '4': '$', '5': '%', '6': '^', '7': '&', '8': '*', '9': '(', '0': ')',
+	'-': '_', '=': '+', '[': '{', ']': '}', '\\': '|', ';': ':', '\'': '"', ',': '<', '.': '>', '/': '?', '`': '~',
+}
+
+// ctrlKeys are the control characters that other keys send, so binding them would take those keys too
+var ctrlKeys = map[rune]string{
+	'B': "the tmux-style prefix", // handled before any binding
+	'I': "Tab",
+	'J': "Enter",
+	'M': "Enter",
+	'Q': "quit 3mux", // handled before any binding
+}
+
+// normalizeKeyCode checks a key code like `Alt+Shift+K` and returns it as it will be reported by Listen.
+// Keys the terminal never sends that way, and keys without Alt or Ctrl that would swallow typing, are rejected
+func normalizeKeyCode(keyCode string) (string, error) {
+	if keyCode == "" {
+		return "", fmt.Errorf("empty key")
+	}
+
+	key := keyCode
+	modifiers := ""
+	if idx := strings.LastIndex(keyCode[:len(keyCode)-1], "+"); idx != -1 && len(keyCode) > 1 {
+		modifiers = keyCode[:idx+1]
+		key = keyCode[idx+1:]
+	}
+
+	var ctrl, alt, shift bool
+	for _, mod := range strings.Split(strings.TrimSuffix(modifiers, "+"), "+") {
+		switch mod {
+		case "":
+		case "Ctrl":
+			ctrl = true
+		case "Alt":
+			alt = true
+		case "Shift":
+			shift = true
+		default:
+			return "", fmt.Errorf("invalid key %q: unknown modifier %q", keyCode, mod)
+		}
+	}
+
+	if key == "" {
+		return "", fmt.Errorf("invalid key %q: missing key", keyCode)
+	}
+
+	switch key {
+	case "Up", "Down", "Left", "Right":
+		if !ctrl && !alt && !shift {
+			return "", fmt.Errorf("invalid key %q: add a modifier so programs still get the key", keyCode)
+		}
+		// Listen reports modifiers in this order
+		human := ""
+		if ctrl {
+			human += "Ctrl+"
+		}
+		if alt {
+			human += "Alt+"
+		}
+		if shift {
+			human += "Shift+"
+		}
+		return human + key, nil
+	case "Enter":
+		if !alt || ctrl || shift {
+			return "", fmt.Errorf("invalid key %q: Enter can only be bound as \"Alt+Enter\"", keyCode)
+		}
+		return "Alt+Enter", nil
+	}
+
+	runes := []rune(key)
+	if len(runes) != 1 {
+		return "", fmt.Errorf("invalid key %q: unknown key %q", keyCode, key)
+	}
+	r := runes[0]
+	isLetter := 'a' <= r && r <= 'z' || 'A' <= r && r <= 'Z'
+
+	switch {
+	case !ctrl && !alt:
+		return "", fmt.Errorf("invalid key %q: add Alt or Ctrl so typing %q still works", keyCode, key)
+	case ctrl && (alt || shift):
+		return "", fmt.Errorf("invalid key %q: the terminal can't send Ctrl with Alt or Shift for %q", keyCode, key)
+	case ctrl:
+		if !isLetter {
+			return "", fmt.Errorf("invalid key %q: the terminal can only send Ctrl with letters", keyCode)
+		}
+		r = unicode.ToUpper(r)
+		if other, ok := ctrlKeys[r]; ok {
+			return "", fmt.Errorf("invalid key %q: it is the same as %s", keyCode, other)
+		}
+		return "Ctrl+" + string(r), nil
+	case shift:
+		if isLetter {
+			return "Alt+Shift+" + string(unicode.ToUpper(r)), nil
+		}
+		if shifted, ok := shiftedKeys[r]; ok {
+			r = shifted
+		}
+		return "", fmt.Errorf("invalid key %q: it is sent as \"Alt+%c\"", keyCode, r)
+	default:
+		// letters are always reported in uppercase
+		return "Alt+" + string(unicode.ToUpper(r)), nil
+	}
 }
 
 var config = Config{
 	statusBar: true,
+	bindings:  map[string]func(){},
+}
+
+// defaultBindings maps operations to the keys that trigger them when the user doesn't say otherwise
+var defaultBindings = map[string][]string{
+	"newWindow":     []string{"Alt+N", "Alt+Enter"},
+	"killWindow":    []string{"Alt+Shift+Q"},
+	"resize":        []string{"Alt+R"},
+	"fullscreen":    []string{"Alt+Shift+F"},
+	"debugSlowMode": []string{"Alt+X"},
+	"search":        []string{"Alt+/"},
+	"paste":         []string{"Alt+P"},
+	"chooseBuffer":  []string{"Alt+Shift+P"},
+	"detach":        []string{"Alt+Shift+D"},
+	"reloadConfig":  []string{"Alt+Shift+C"},
+
+	"moveWindowUp":    []string{"Alt+Shift+K", "Alt+Shift+Up"},
+	"moveWindowDown":  []string{"Alt+Shift+J", "Alt+Shift+Down"},
+	"moveWindowLeft":  []string{"Alt+Shift+H", "Alt+Shift+Left"},
+	"moveWindowRight": []string{"Alt+Shift+L", "Alt+Shift+Right"},
+
+	"layoutSplit":   []string{"Alt+E"},
+	"layoutTabbed":  []string{"Alt+W"},
+	"layoutStacked": []string{"Alt+S"},
+
+	"splitHorizontal": []string{"Alt+B"},
+	"splitVertical":   []string{"Alt+V"},
+	"focusParent":     []string{"Alt+A"},
+	"focusChild":      []string{"Alt+Shift+A"},
+
+	"equalize": []string{"Alt+="},
+
+	"mark":         []string{"Alt+M"},
+	"swapWithMark": []string{"Alt+Shift+M"},
+	"rotate":       []string{"Alt+O"},
+
+	"toggleFloating": []string{"Alt+Shift+G"},
+	"focusFloating":  []string{"Alt+G"},
+
+	"moveToScratchpad": []string{"Alt+_"},
+	"showScratchpad":   []string{"Alt+-"},
+
+	"moveSelectionUp":    []string{"Alt+K", "Alt+Up"},
+	"moveSelectionDown":  []string{"Alt+J", "Alt+Down"},
+	"moveSelectionLeft":  []string{"Alt+H", "Alt+Left"},
+	"moveSelectionRight": []string{"Alt+L", "Alt+Right"},
+}
+
+// workspaceKeys are the keys for workspaces 1 through 9 when pressed with Alt and with Alt+Shift (US layout)
+var workspaceKeys = []struct{ key, shifted string }{
+	{"1", "!"}, {"2", "@"}, {"3", "#"}, {"4", "$"}, {"5", "%"},
+	{"6", "^"}, {"7", "&"}, {"8", "*"}, {"9", "("},
 }
 
 func init() {
-	config.bindings = compileBindings(map[string][]string{
-		"newWindow":     []string{"Alt+N", "Alt+Enter"},
-		"killWindow":    []string{"Alt+Shift+Q"},
-		"resize":        []string{"Alt+R"},
-		"fullscreen":    []string{"Alt+Shift+F"},
-		"debugSlowMode": []string{"Alt+X"},
-		"search":        []string{"Alt+/"},
-
-		"moveWindowUp":    []string{"Alt+Shift+K", "Alt+Shift+Up"},
-		"moveWindowDown":  []string{"Alt+Shift+J", "Alt+Shift+Down"},
-		"moveWindowLeft":  []string{"Alt+Shift+H", "Alt+Shift+Left"},
-		"moveWindowRight": []string{"Alt+Shift+L", "Alt+Shift+Right"},
-
-		"moveSelectionUp":    []string{"Alt+K", "Alt+Up"},
-		"moveSelectionDown":  []string{"Alt+J", "Alt+Down"},
-		"moveSelectionLeft":  []string{"Alt+H", "Alt+Left"},
-		"moveSelectionRight": []string{"Alt+L", "Alt+Right"},
-	})
+	configFuncBindings["reloadConfig"] = reloadConfig
+
+	for idx, keys := range workspaceKeys {
+		n := idx + 1
+		switchName := fmt.Sprintf("workspace%d", n)
+		moveName := fmt.Sprintf("moveToWorkspace%d", n)
+
+		configFuncBindings[switchName] = func() { switchWorkspace(n) }
+		configFuncBindings[moveName] = func() { movePaneToWorkspace(n) }
+
+		defaultBindings[switchName] = []string{"Alt+" + keys.key}
+		defaultBindings[moveName] = []string{"Alt+" + keys.shifted}
+	}
+}
+
+// configFile is the format of the user's config file
+type configFile struct {
+	StatusBar    *bool               `toml:"status-bar"`
+	RemainOnExit bool                `toml:"remain-on-exit"`
+	Clipboard    string              `toml:"clipboard"`
+	Keys         map[string][]string `toml:"keys"`
+}
+
+func configPath() string {
+	dir := os.Getenv("XDG_CONFIG_HOME")
+	if dir == "" {
+		dir = filepath.Join(os.Getenv("HOME"), ".config")
+	}
+	return filepath.Join(dir, "3mux", "config.toml")
+}
+
+// loadConfig reads the user's config file on top of the defaults. A missing config file is not an error
+func loadConfig() (Config, error) {
+	path := configPath()
+
+	var file configFile
+	md, err := toml.DecodeFile(path, &file)
+	if err != nil && !os.IsNotExist(err) {
+		return Config{}, fmt.Errorf("%s: %s", path, err.Error())
+	}
+	if undecoded := md.Undecoded(); len(undecoded) > 0 {
+		return Config{}, fmt.Errorf("%s: unknown option %q", path, undecoded[0].String())
+	}
+
+	c := Config{
+		statusBar:    true,
+		remainOnExit: file.RemainOnExit,
+		clipboard:    ClipboardAllow,
+		bindings:     map[string]func(){},
+	}
+	if file.StatusBar != nil {
+		c.statusBar = *file.StatusBar
+	}
+	if file.Clipboard != "" {
+		policy, err := getClipboardPolicyFromString(strings.Title(file.Clipboard))
+		if err != nil || policy == ClipboardDefault {
+			return Config{}, fmt.Errorf("%s: clipboard must be allow, deny, or ask", path)
+		}
+		c.clipboard = policy
+	}
+
+	// the user's bindings replace the defaults for the same operation and win any conflicts over keys
+	sourceBindings := map[string][]string{}
+	for op, keyCodes := range defaultBindings {
+		if _, ok := file.Keys[op]; !ok {
+			sourceBindings[op] = keyCodes
+		}
+	}
+	if err := compileBindings(sourceBindings, c.bindings); err != nil {
+		return Config{}, fmt.Errorf("default config: %s", err.Error())
+	}
+	if err := compileBindings(file.Keys, c.bindings); err != nil {
+		return Config{}, fmt.Errorf("%s: %s", path, err.Error())
+	}
+
+	return c, nil
 }
 
 func seiveConfigEvents(human string) bool {
@@ -153,70 +498,319 @@ func seiveConfigEvents(human string) bool {
 	return false
 }
 
-func executeOperationCode(s string) {
-	sections := strings.Split(s, "(")
-
-	funcName := sections[0]
-
-	var parametersText string
-	if len(sections) < 2 {
-		parametersText = ""
-	} else {
-		parametersText = strings.TrimRight(sections[1], ")")
-	}
-	params := strings.Split(parametersText, ",")
-	for idx, param := range params {
-		params[idx] = strings.TrimSpace(param)
-	}
-
-	if root.workspaces[root.selectionIdx].doFullscreen {
-		switch funcName {
-		case "fullscreen":
-			unfullscreen()
-		case "killWindow":
-			unfullscreen()
-			killWindow()
-		case "search":
-			search()
-		}
-	} else {
-		switch funcName {
-		case "search":
-			search()
-		case "fullscreen":
-			fullscreen()
-		case "newWindow":
-			root.AddPane()
-		case "moveWindow":
-			d := getDirectionFromString(params[0])
-			moveWindow(d)
-		case "moveSelection":
-			d := getDirectionFromString(params[0])
-			moveSelection(d)
-		case "killWindow":
-			killWindow()
-		case "resize":
-			resizeMode = true
-		case "debugSlowMode":
-			log.Println("slowmo enabled!")
-			if getSelection().getContainer().(*Pane).vterm.DebugSlowMode {
-				dbug := ""
-				scr := getSelection().getContainer().(*Pane).vterm.Screen
-				for _, row := range scr {
-					for _, ch := range row {
-						dbug += string(ch.Rune)
-					}
-					dbug += "\n"
+// reloadConfig applies changes to the config file without restarting.
+// If the file is invalid, the error is shown in the status bar and the current config is kept.
+func reloadConfig() {
+	newConfig, err := loadConfig()
+	if err != nil {
+		log.Println(err.Error())
+		statusError = err.Error()
+		refreshStatusBar()
+		return
+	}
+
+	config = newConfig
+	statusError = ""
+	resize(termW, termH)
+	refreshStatusBar()
+}
+
+// parseOperationCode splits an operation like `sendKeys(3, "ls\n")` into its name and parameters.
+// Parameters may be double-quoted Go strings, which can hold commas and escape sequences.
+func parseOperationCode(s string) (string, []string, error) {
+	open := strings.Index(s, "(")
+	if open == -1 {
+		return strings.TrimSpace(s), []string{""}, nil
+	}
+
+	funcName := strings.TrimSpace(s[:open])
+	parametersText := strings.TrimSpace(s[open+1:])
+	if !strings.HasSuffix(parametersText, ")") {
+		return "", nil, fmt.Errorf("missing closing parenthesis: %s", s)
+	}
+	parametersText = parametersText[:len(parametersText)-1]
+
+	params := []string{}
+	for {
+		parametersText = strings.TrimLeft(parametersText, " ")
+
+		var param string
+		if strings.HasPrefix(parametersText, "\"") {
+			end := 1
+			for end < len(parametersText) && parametersText[end] != '"' {
+				if parametersText[end] == '\\' {
+					end++
 				}
-				log.Println("=== SCREEN OUTPUT ===")
-				log.Println(dbug)
-				getSelection().getContainer().(*Pane).vterm.DebugSlowMode = false
-			} else {
-				getSelection().getContainer().(*Pane).vterm.DebugSlowMode = true
+				end++
 			}
-		default:
-			panic(funcName)
+			if end >= len(parametersText) {
+				return "", nil, fmt.Errorf("unterminated string: %s", s)
+			}
+
+			var err error
+			param, err = strconv.Unquote(parametersText[:end+1])
+			if err != nil {
+				return "", nil, fmt.Errorf("invalid string: %s", parametersText[:end+1])
+			}
+
+			parametersText = strings.TrimLeft(parametersText[end+1:], " ")
+			if parametersText != "" && parametersText[0] != ',' {
+				return "", nil, fmt.Errorf("expected comma after string: %s", s)
+			}
+		} else {
+			comma := strings.Index(parametersText, ",")
+			if comma == -1 {
+				comma = len(parametersText)
+			}
+			param = strings.TrimSpace(parametersText[:comma])
+			parametersText = parametersText[comma:]
+		}
+
+		params = append(params, param)
+		if parametersText == "" {
+			break
+		}
+		parametersText = parametersText[1:] // skip the comma
+	}
+
+	return funcName, params, nil
+}
+
+// parseNewWindowParams reads the parameters of an operation like `newWindow(cwd=~/src, env=DEBUG=1, "npm", "test")`.
+// Leading `cwd=` and `env=` options are followed by the command and its arguments, which default to the user's shell.
+// The directory is returned as written, since a relative one depends on the focused pane when the operation runs.
+func parseNewWindowParams(params []string) (string, []string, []string, error) {
+	if len(params) == 1 && params[0] == "" {
+		return "", nil, nil, nil
+	}
+
+	dir := ""
+	env := []string{}
+	for len(params) > 0 {
+		if strings.HasPrefix(params[0], "cwd=") {
+			dir = strings.TrimPrefix(params[0], "cwd=")
+		} else if strings.HasPrefix(params[0], "env=") {
+			variable := strings.TrimPrefix(params[0], "env=")
+			if !strings.Contains(variable, "=") {
+				return "", nil, nil, fmt.Errorf("invalid environment variable: %s", variable)
+			}
+			env = append(env, variable)
+		} else {
+			break
 		}
+		params = params[1:]
+	}
+
+	if len(params) > 0 {
+		if _, err := exec.LookPath(params[0]); err != nil {
+			return "", nil, nil, err
+		}
+	}
+
+	return dir, params, env, nil
+}
 
+func executeOperationCode(s string) error {
+	funcName, params, err := parseOperationCode(s)
+	if err != nil {
+		return err
 	}
+
+	switch funcName {
+	case "saveLayout":
+		path := params[0]
+		if path == "" {
+			path = sessionFile(server.name)
+		}
+		return saveLayout(path)
+	case "focusPane":
+		loc, err := getPaneFromString(params[0])
+		if err != nil {
+			return err
+		}
+		focusPane(loc)
+		return nil
+	case "killPane":
+		loc, err := getPaneFromString(params[0])
+		if err != nil {
+			return err
+		}
+		killPaneAt(loc)
+		return nil
+	case "respawnPane":
+		pane := root.workspaces[root.selectionIdx].focusedPane()
+		if params[0] != "" {
+			loc, err := getPaneFromString(params[0])
+			if err != nil {
+				return err
+			}
+			pane = loc.pane
+		}
+		if !pane.exited {
+			return fmt.Errorf("pane %%%d is still running", pane.id)
+		}
+		pane.respawn()
+		return nil
+	case "mark":
+		pane := root.workspaces[root.selectionIdx].focusedPane()
+		if params[0] != "" {
+			loc, err := getPaneFromString(params[0])
+			if err != nil {
+				return err
+			}
+			pane = loc.pane
+		}
+		markPane(pane)
+		return nil
+	case "sendKeys":
+		var pane *Pane
+		switch len(params) {
+		case 1:
+			pane = root.workspaces[root.selectionIdx].focusedPane()
+		case 2:
+			loc, err := getPaneFromString(params[0])
+			if err != nil {
+				return err
+			}
+			pane = loc.pane
+		default:
+			return fmt.Errorf("sendKeys takes a pane id and text")
+		}
+		pane.handleStdin(params[len(params)-1])
+		return nil
+	case "clipboard":
+		pane := root.workspaces[root.selectionIdx].focusedPane()
+		switch len(params) {
+		case 1:
+		case 2:
+			loc, err := getPaneFromString(params[0])
+			if err != nil {
+				return err
+			}
+			pane = loc.pane
+		default:
+			return fmt.Errorf("clipboard takes a pane id and a policy")
+		}
+		policy, err := getClipboardPolicyFromString(params[len(params)-1])
+		if err != nil {
+			return err
+		}
+		pane.clipboard = policy
+	case "pasteBuffer":
+		b, err := getBufferFromString(params[0])
+		if err != nil {
+			return err
+		}
+		pasteText(b.text)
+	case "setBuffer":
+		switch len(params) {
+		case 1:
+			addBuffer(params[0])
+		case 2:
+			setBuffer(params[0], params[1])
+		default:
+			return fmt.Errorf("setBuffer takes a buffer name and text")
+		}
+	case "deleteBuffer":
+		idx := findBuffer(params[0])
+		if idx == -1 {
+			return fmt.Errorf("no buffer named %s", params[0])
+		}
+		deleteBuffer(idx)
+	case "search":
+		search()
+	case "detach":
+		detach()
+	case "newWindow":
+		dir, args, env, err := parseNewWindowParams(params)
+		if err != nil {
+			return err
+		}
+		if dir != "" {
+			// relative to where the pane would start without cwd=
+			dir = expandPath(dir, newPaneDir())
+			if info, err := os.Stat(dir); err != nil {
+				return err
+			} else if !info.IsDir() {
+				return fmt.Errorf("not a directory: %s", dir)
+			}
+		}
+		root.AddPaneWithCommand(dir, args, env)
+	case "workspace":
+		n, err := getWorkspaceFromString(params[0])
+		if err != nil {
+			return err
+		}
+		switchWorkspace(n)
+	case "moveToWorkspace":
+		n, err := getWorkspaceFromString(params[0])
+		if err != nil {
+			return err
+		}
+		movePaneToWorkspace(n)
+	case "moveWindow":
+		d, err := getDirectionFromString(params[0])
+		if err != nil {
+			return err
+		}
+		moveWindow(d)
+	case "moveSelection":
+		d, err := getDirectionFromString(params[0])
+		if err != nil {
+			return err
+		}
+		moveSelection(d)
+	case "resizeWindow":
+		d, err := getDirectionFromString(params[0])
+		if err != nil {
+			return err
+		}
+		resizeWindow(d, 0.1)
+	case "split":
+		vert, err := getOrientationFromString(params[0])
+		if err != nil {
+			return err
+		}
+		splitPane(vert)
+	case "layout":
+		mode, err := getSplitModeFromString(params[0])
+		if err != nil {
+			return err
+		}
+		setSplitMode(mode)
+	case "swapPane":
+		loc, err := getPaneFromString(params[0])
+		if err != nil {
+			return err
+		}
+		swapWithPane(loc)
+	case "swapWithMark":
+		return swapWithMark()
+	case "rotate":
+		if params[0] != "" && params[0] != "Reverse" {
+			return fmt.Errorf("invalid direction: %s", params[0])
+		}
+		rotateSplit(params[0] == "Reverse")
+	case "selectLayout":
+		p, err := getPresetFromString(params[0])
+		if err != nil {
+			return err
+		}
+		selectLayout(p)
+	case "killWindow":
+		killWindow()
+	case "resize":
+		unzoom()
+		resizeMode = true
+	case "debugSlowMode":
+		configFuncBindings["debugSlowMode"]()
+	default:
+		if fn, ok := configFuncBindings[funcName]; ok {
+			fn()
+		} else {
+			return fmt.Errorf("unknown operation: %s", funcName)
+		}
+	}
+
+	return nil
 }
@@ -0,0 +1,109 @@
+package main
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestNormalizeKeyCode(t *testing.T) {
+	tests := []struct {
+		keyCode string
+		human   string // empty if the key code is invalid
+	}{
+		{"Alt+n", "Alt+N"},
+		{"Alt+N", "Alt+N"},
+		{"Alt+Shift+k", "Alt+Shift+K"},
+		{"Alt+Enter", "Alt+Enter"},
+		{"Alt+!", "Alt+!"},
+		{"Alt+/", "Alt+/"},
+		{"Alt++", "Alt++"},
+		{"Ctrl+g", "Ctrl+G"},
+		{"Alt+Up", "Alt+Up"},
+		{"Shift+Alt+Left", "Alt+Shift+Left"},
+		{"Alt+Ctrl+Down", "Ctrl+Alt+Down"},
+
+		{"", ""},
+		{"Alt+", ""},
+		{"Meta+K", ""},
+		{"Alt+Home", ""},
+		{"k", ""},
+		{"Up", ""},
+		{"Enter", ""},
+		{"Shift+K", ""},
+		{"Alt+Shift+1", ""},
+		{"Alt+Shift+/", ""},
+		{"Alt+Shift+!", ""},
+		{"Ctrl+Alt+K", ""},
+		{"Ctrl+1", ""},
+		{"Ctrl+b", ""},
+		{"Ctrl+M", ""},
+		{"Ctrl+Q", ""},
+	}
+
+	for _, test := range tests {
+		human, err := normalizeKeyCode(test.keyCode)
+		if test.human == "" {
+			if err == nil {
+				t.Errorf("normalizeKeyCode(%q) = %q, want an error", test.keyCode, human)
+			}
+		} else if err != nil {
+			t.Errorf("normalizeKeyCode(%q) failed: %s", test.keyCode, err)
+		} else if human != test.human {
+			t.Errorf("normalizeKeyCode(%q) = %q, want %q", test.keyCode, human, test.human)
+		}
+	}
+}
+
+func TestNormalizeKeyCodeNamesEmittedKey(t *testing.T) {
+	_, err := normalizeKeyCode("Alt+Shift+1")
+	if err == nil || err.Error() != `invalid key "Alt+Shift+1": it is sent as "Alt+!"` {
+		t.Errorf("got %v", err)
+	}
+}
+
+func TestDefaultBindingsAreValid(t *testing.T) {
+	for op, keyCodes := range defaultBindings {
+		for _, keyCode := range keyCodes {
+			if _, err := normalizeKeyCode(keyCode); err != nil {
+				t.Errorf("default binding for %s: %s", op, err)
+			}
+		}
+	}
+}
+
+func TestParseOperationCode(t *testing.T) {
+	tests := []struct {
+		code     string
+		funcName string
+		params   []string // nil if the code is invalid
+	}{
+		{"split", "split", []string{""}},
+		{"split()", "split", []string{""}},
+		{" focusPane( %3 ) ", "focusPane", []string{"%3"}},
+		{"swapPane(%1, %2)", "swapPane", []string{"%1", "%2"}},
+		{`sendKeys(%1, "ls -la\r")`, "sendKeys", []string{"%1", "ls -la\r"}},
+		{`sendKeys("a, b")`, "sendKeys", []string{"a, b"}},
+		{`sendKeys("say \"hi\"")`, "sendKeys", []string{`say "hi"`}},
+		{`sendKeys("f(x)")`, "sendKeys", []string{"f(x)"}},
+		{`sendKeys("")`, "sendKeys", []string{""}},
+		{`newWindow(cwd=~/src, "npm", "test")`, "newWindow", []string{"cwd=~/src", "npm", "test"}},
+
+		{"split(", "", nil},
+		{`sendKeys("abc)`, "", nil},
+		{`sendKeys("a" b)`, "", nil},
+		{`sendKeys("\q")`, "", nil},
+	}
+
+	for _, test := range tests {
+		funcName, params, err := parseOperationCode(test.code)
+		if test.params == nil {
+			if err == nil {
+				t.Errorf("parseOperationCode(%q) = %q %q, want an error", test.code, funcName, params)
+			}
+		} else if err != nil {
+			t.Errorf("parseOperationCode(%q) failed: %s", test.code, err)
+		} else if funcName != test.funcName || !reflect.DeepEqual(params, test.params) {
+			t.Errorf("parseOperationCode(%q) = %q %q, want %q %q", test.code, funcName, params, test.funcName, test.params)
+		}
+	}
+}
@@ -0,0 +1,629 @@
+package main
+
+import (
+	"fmt"
+	"strings"
+	"unicode"
+
+	"github.com/aaronjanse/3mux/ecma48"
+	"github.com/aaronjanse/3mux/render"
+)
+
+// A VisualMode is the kind of selection being made in copy mode, like vi's visual modes
+type VisualMode int
+
+// visual modes
+const (
+	VisualNone  VisualMode = iota
+	VisualChar             // v
+	VisualLine             // V
+	VisualBlock            // Ctrl+V
+)
+
+// CopyMode is the state of a pane that is being browsed with vi keys.
+// Positions are in the pane's buffer, which is its scrollback followed by its screen.
+type CopyMode struct {
+	x, y int
+
+	visual           VisualMode
+	anchorX, anchorY int // where the selection started
+
+	pendingG bool // the first g of gg was pressed
+
+	// searching is set while a search is typed after / or ?
+	searching      bool
+	searchText     string
+	searchBackward bool
+}
+
+// copyModeStyle is used for the cursor and the status text, the same colors as search mode
+var copyModeStyle = render.Style{
+	Bg: ecma48.Color{
+		ColorMode: ecma48.ColorBit3Bright,
+		Code:      2,
+	},
+	Fg: ecma48.Color{
+		ColorMode: ecma48.ColorBit3Normal,
+		Code:      0,
+	},
+}
+
+// enterCopyMode stops the pane's output and puts a cursor where the pane's own cursor is
+func (t *Pane) enterCopyMode() {
+	if t.copyMode != nil || t.searchMode {
+		return
+	}
+
+	t.setFrozen(true)
+
+	t.copyMode = &CopyMode{
+		x: t.vterm.Cursor.X,
+		y: t.scrollbackLen() + t.vterm.Cursor.Y,
+	}
+
+	// if we were scrolled up, start at the bottom of what's shown instead
+	bottom := t.scrollbackLen() - t.vterm.ScrollbackPos + t.renderRect.h - 1
+	if t.copyMode.y > bottom {
+		t.copyMode.x = 0
+		t.copyMode.y = bottom
+	}
+
+	t.drawCopyMode()
+}
+
+func (t *Pane) exitCopyMode() {
+	t.copyMode = nil
+
+	t.vterm.ScrollbackPos = 0
+	t.vterm.RedrawWindow()
+	t.setFrozen(false)
+}
+
+func (t *Pane) handleCopyModeInput(in string) {
+	switch in {
+	case "\x1bOA", "\x1b[A":
+		in = "k"
+	case "\x1bOB", "\x1b[B":
+		in = "j"
+	case "\x1bOC", "\x1b[C":
+		in = "l"
+	case "\x1bOD", "\x1b[D":
+		in = "h"
+	}
+
+	for _, r := range in {
+		if t.copyMode.searching {
+			t.handleCopyModeSearchKey(r)
+		} else {
+			t.handleCopyModeKey(r)
+		}
+		if t.copyMode == nil {
+			return
+		}
+		t.clampCopyMode()
+	}
+
+	t.drawCopyMode()
+}
+
+// handleCopyModeSearchKey edits the text typed after / or ?, searching for it on enter
+func (t *Pane) handleCopyModeSearchKey(r rune) {
+	c := t.copyMode
+	switch r {
+	case 3, 27: // Ctrl+C, Escape
+		c.searching = false
+	case 8, 127: // backspace
+		if len(c.searchText) > 0 {
+			c.searchText = c.searchText[:len(c.searchText)-1]
+		}
+	case 10, 13:
+		c.searching = false
+		t.copyModeSearch(c.searchBackward)
+	default:
+		c.searchText += string(r)
+	}
+}
+
+func (t *Pane) handleCopyModeKey(r rune) {
+	c := t.copyMode
+	h := t.renderRect.h
+
+	if c.pendingG {
+		c.pendingG = false
+		if r == 'g' {
+			c.x = 0
+			c.y = 0
+		}
+		return
+	}
+
+	switch r {
+	case 'h':
+		c.x--
+	case 'l':
+		c.x++
+	case 'j':
+		c.y++
+	case 'k':
+		c.y--
+	case '0':
+		c.x = 0
+	case '$':
+		runes := t.copyModeRunes(c.y)
+		c.x = len(runes) - 1
+		for c.x > 0 && runes[c.x] == ' ' {
+			c.x--
+		}
+	case 'w':
+		t.copyModeWordForward()
+	case 'b':
+		t.copyModeWordBackward()
+	case 'g':
+		c.pendingG = true
+	case 'G':
+		c.x = 0
+		c.y = t.bufferLen() - 1
+	case 4: // Ctrl+D
+		c.y += h / 2
+		t.vterm.ScrollbackPos -= h / 2
+	case 21: // Ctrl+U
+		c.y -= h / 2
+		t.vterm.ScrollbackPos += h / 2
+	case 'v':
+		t.toggleVisual(VisualChar)
+	case 'V':
+		t.toggleVisual(VisualLine)
+	case 22: // Ctrl+V
+		t.toggleVisual(VisualBlock)
+	case '/', '?':
+		c.searching = true
+		c.searchText = ""
+		c.searchBackward = r == '?'
+	case 'n':
+		t.copyModeSearch(c.searchBackward)
+	case 'N':
+		t.copyModeSearch(!c.searchBackward)
+	case 'y':
+		if c.visual != VisualNone {
+			yank(t.copyModeSelection())
+			t.exitCopyMode()
+		}
+	case 10, 13: // enter
+		if c.visual != VisualNone {
+			yank(t.copyModeSelection())
+		}
+		t.exitCopyMode()
+	case 27: // escape
+		if c.visual != VisualNone {
+			c.visual = VisualNone
+		} else {
+			t.exitCopyMode()
+		}
+	case 'q', 3: // Ctrl+C
+		t.exitCopyMode()
+	}
+}
+
+// startMouseSelection enters copy mode with a selection starting at the given screen coordinates
+func (t *Pane) startMouseSelection(x, y int, mode VisualMode) {
+	t.enterCopyMode()
+	c := t.copyMode
+	if c == nil {
+		return
+	}
+
+	c.x, c.y = t.copyModePos(x, y)
+	c.anchorX, c.anchorY = c.x, c.y
+	c.visual = mode
+	t.drawCopyMode()
+}
+
+// extendMouseSelection moves the end of the selection to the given screen coordinates.
+// Dragging past the top or bottom of the pane scrolls it.
+func (t *Pane) extendMouseSelection(x, y int) {
+	c := t.copyMode
+	if c == nil {
+		return
+	}
+
+	c.x, c.y = t.copyModePos(x, y)
+	t.drawCopyMode()
+}
+
+// selectWordAt enters copy mode with the word at the given screen coordinates selected
+func (t *Pane) selectWordAt(x, y int) {
+	t.startMouseSelection(x, y, VisualChar)
+	c := t.copyMode
+	if c == nil {
+		return
+	}
+
+	runes := t.copyModeRunes(c.y)
+	class := runeClass(runes[c.x])
+	for c.anchorX > 0 && runeClass(runes[c.anchorX-1]) == class {
+		c.anchorX--
+	}
+	for c.x+1 < len(runes) && runeClass(runes[c.x+1]) == class {
+		c.x++
+	}
+	t.drawCopyMode()
+}
+
+// finishMouseSelection yanks the selection and leaves copy mode
+func (t *Pane) finishMouseSelection() {
+	c := t.copyMode
+	if c == nil {
+		return
+	}
+
+	if c.visual != VisualNone {
+		yank(t.copyModeSelection())
+	}
+	t.exitCopyMode()
+}
+
+// copyModePos converts screen coordinates to a position in the pane's buffer, keeping it within the pane's columns
+func (t *Pane) copyModePos(x, y int) (int, int) {
+	r := t.renderRect
+	x -= r.x
+	if x < 0 {
+		x = 0
+	} else if x >= r.w {
+		x = r.w - 1
+	}
+	return x, t.scrollbackLen() - t.vterm.ScrollbackPos + y - r.y
+}
+
+// toggleVisual starts a selection at the cursor, or ends it if one of the same kind is being made
+func (t *Pane) toggleVisual(mode VisualMode) {
+	c := t.copyMode
+	if c.visual == mode {
+		c.visual = VisualNone
+		return
+	}
+	if c.visual == VisualNone {
+		c.anchorX, c.anchorY = c.x, c.y
+	}
+	c.visual = mode
+}
+
+// clampCopyMode keeps the cursor within the buffer and scrolls to show it
+func (t *Pane) clampCopyMode() {
+	c := t.copyMode
+	n := t.scrollbackLen()
+	h := t.renderRect.h
+
+	if c.y >= t.bufferLen() {
+		c.y = t.bufferLen() - 1
+	}
+	if c.y < 0 {
+		c.y = 0
+	}
+	if c.x >= t.renderRect.w {
+		c.x = t.renderRect.w - 1
+	}
+	if c.x < 0 {
+		c.x = 0
+	}
+
+	if c.y < n-t.vterm.ScrollbackPos {
+		t.vterm.ScrollbackPos = n - c.y
+	} else if c.y > n-t.vterm.ScrollbackPos+h-1 {
+		t.vterm.ScrollbackPos = n - c.y + h - 1
+	}
+	if t.vterm.ScrollbackPos > n {
+		t.vterm.ScrollbackPos = n
+	}
+	if t.vterm.ScrollbackPos < 0 {
+		t.vterm.ScrollbackPos = 0
+	}
+}
+
+// followCopyMode moves the cursor back into view after scrolling with the mouse
+func (t *Pane) followCopyMode() {
+	c := t.copyMode
+	top := t.scrollbackLen() - t.vterm.ScrollbackPos
+	if c.y < top {
+		c.y = top
+	} else if c.y > top+t.renderRect.h-1 {
+		c.y = top + t.renderRect.h - 1
+	}
+	t.drawCopyMode()
+}
+
+// drawCopyMode draws the visible part of the buffer with the cursor and selection over it
+func (t *Pane) drawCopyMode() {
+	t.clampCopyMode()
+	if t.vterm.IsPaused {
+		return
+	}
+
+	t.vterm.RedrawWindow()
+
+	c := t.copyMode
+	r := t.renderRect
+	top := t.scrollbackLen() - t.vterm.ScrollbackPos
+	for row := 0; row < r.h; row++ {
+		y := top + row
+		line := t.bufferLine(y)
+		for x := 0; x < r.w; x++ {
+			cursor := x == c.x && y == c.y
+			if !cursor && !t.copyModeSelected(x, y) {
+				continue
+			}
+
+			ch := render.Char{Rune: ' '}
+			if x < len(line) {
+				ch = line[x]
+			}
+			if ch.Rune == 0 && !ch.PrevWide {
+				ch.Rune = ' '
+			}
+
+			style := ch.Style
+			if cursor {
+				style = copyModeStyle
+			} else {
+				style.Reverse = !style.Reverse
+			}
+
+			renderer.HandleLayeredCh(render.PositionedChar{
+				Rune:     ch.Rune,
+				IsWide:   ch.IsWide,
+				PrevWide: ch.PrevWide,
+				Cursor: render.Cursor{
+					X: r.x + x, Y: r.y + row, Style: style,
+				},
+			}, t.vterm.Layer)
+		}
+	}
+
+	// how far back we are, like tmux
+	pos := fmt.Sprintf("[%d/%d]", t.vterm.ScrollbackPos, t.scrollbackLen())
+	for i, ch := range pos {
+		if len(pos)-i > r.w {
+			continue
+		}
+		renderer.HandleLayeredCh(render.PositionedChar{
+			Rune: ch,
+			Cursor: render.Cursor{
+				X: r.x + r.w - len(pos) + i, Y: r.y, Style: copyModeStyle,
+			},
+		}, t.vterm.Layer)
+	}
+
+	if c.searching {
+		prompt := "/"
+		if c.searchBackward {
+			prompt = "?"
+		}
+		t.displayStatusText(prompt + c.searchText)
+	}
+}
+
+// copyModeSelected returns whether the given position in the buffer is selected
+func (t *Pane) copyModeSelected(x, y int) bool {
+	c := t.copyMode
+	x1, y1, x2, y2 := c.anchorX, c.anchorY, c.x, c.y
+
+	switch c.visual {
+	case VisualChar:
+		if y1 > y2 || (y1 == y2 && x1 > x2) {
+			x1, y1, x2, y2 = x2, y2, x1, y1
+		}
+		if y < y1 || y > y2 {
+			return false
+		}
+		return (y > y1 || x >= x1) && (y < y2 || x <= x2)
+	case VisualLine:
+		return (y1 <= y && y <= y2) || (y2 <= y && y <= y1)
+	case VisualBlock:
+		return ((y1 <= y && y <= y2) || (y2 <= y && y <= y1)) &&
+			((x1 <= x && x <= x2) || (x2 <= x && x <= x1))
+	}
+	return false
+}
+
+// copyModeSelection returns the selected text, with trailing spaces removed from each line
+func (t *Pane) copyModeSelection() string {
+	c := t.copyMode
+	x1, y1, x2, y2 := c.anchorX, c.anchorY, c.x, c.y
+	if y1 > y2 || (y1 == y2 && x1 > x2) {
+		x1, y1, x2, y2 = x2, y2, x1, y1
+	}
+	w := t.renderRect.w
+
+	lines := []string{}
+	for y := y1; y <= y2; y++ {
+		switch c.visual {
+		case VisualChar:
+			from, to := 0, w-1
+			if y == y1 {
+				from = x1
+			}
+			if y == y2 {
+				to = x2
+			}
+			lines = append(lines, t.copyModeText(y, from, to))
+		case VisualLine:
+			lines = append(lines, t.copyModeText(y, 0, w-1))
+		case VisualBlock:
+			if x1 > x2 {
+				lines = append(lines, t.copyModeText(y, x2, x1))
+			} else {
+				lines = append(lines, t.copyModeText(y, x1, x2))
+			}
+		}
+	}
+
+	return strings.Join(lines, "\n")
+}
+
+// copyModeText returns the text of a line of the buffer from column x1 through x2
+func (t *Pane) copyModeText(y, x1, x2 int) string {
+	line := t.bufferLine(y)
+
+	var str strings.Builder
+	for x := x1; x <= x2 && x < len(line); x++ {
+		if line[x].PrevWide {
+			continue
+		}
+		if line[x].Rune == 0 {
+			str.WriteRune(' ')
+		} else {
+			str.WriteRune(line[x].Rune)
+		}
+	}
+
+	return strings.TrimRight(str.String(), " ")
+}
+
+// copyModeRunes returns a line of the buffer with one rune per column, for searching and word motions
+func (t *Pane) copyModeRunes(y int) []rune {
+	line := t.bufferLine(y)
+	runes := make([]rune, t.renderRect.w)
+	for x := range runes {
+		runes[x] = ' '
+		if x < len(line) && line[x].Rune != 0 {
+			runes[x] = line[x].Rune
+		}
+	}
+	return runes
+}
+
+// copyModeSearch moves the cursor to the next match of the search text, wrapping around the buffer
+func (t *Pane) copyModeSearch(backward bool) {
+	c := t.copyMode
+	text := []rune(c.searchText)
+	if len(text) == 0 {
+		return
+	}
+
+	total := t.bufferLen()
+	for i := 0; i <= total; i++ {
+		var y, x int
+		if backward {
+			y = ((c.y-i)%total + total) % total
+			to := t.renderRect.w
+			if i == 0 {
+				to = c.x
+			}
+			x = lastIndexRunes(t.copyModeRunes(y), text, to)
+		} else {
+			y = (c.y + i) % total
+			from := 0
+			if i == 0 {
+				from = c.x + 1
+			}
+			x = indexRunes(t.copyModeRunes(y), text, from)
+		}
+		if x != -1 {
+			c.x, c.y = x, y
+			return
+		}
+	}
+}
+
+// indexRunes returns the first position at or after from where sub is found in s, or -1
+func indexRunes(s, sub []rune, from int) int {
+	for i := from; i+len(sub) <= len(s); i++ {
+		if string(s[i:i+len(sub)]) == string(sub) {
+			return i
+		}
+	}
+	return -1
+}
+
+// lastIndexRunes returns the last position before to where sub is found in s, or -1
+func lastIndexRunes(s, sub []rune, to int) int {
+	for i := to - 1; i >= 0; i-- {
+		if i+len(sub) <= len(s) && string(s[i:i+len(sub)]) == string(sub) {
+			return i
+		}
+	}
+	return -1
+}
+
+// runeClass splits runes into spaces, word characters, and punctuation for word motions
+func runeClass(r rune) int {
+	switch {
+	case unicode.IsSpace(r):
+		return 0
+	case r == '_' || unicode.IsLetter(r) || unicode.IsDigit(r):
+		return 1
+	default:
+		return 2
+	}
+}
+
+// copyModeWordForward moves to the start of the next word, like vi's w. Line breaks count as spaces.
+func (t *Pane) copyModeWordForward() {
+	c := t.copyMode
+	w := t.renderRect.w
+	at := func(p int) int { return runeClass(t.copyModeRunes(p / w)[p%w]) }
+
+	total := t.bufferLen() * w
+	p := c.y*w + c.x
+
+	// skip the rest of this word, then any spaces
+	class := at(p)
+	for class != 0 && p+1 < total && (p+1)%w != 0 && at(p+1) == class {
+		p++
+	}
+	p++
+	for p < total && at(p) == 0 {
+		p++
+	}
+	if p >= total {
+		p = total - 1
+	}
+
+	c.x, c.y = p%w, p/w
+}
+
+// copyModeWordBackward moves to the start of this word or the previous one, like vi's b
+func (t *Pane) copyModeWordBackward() {
+	c := t.copyMode
+	w := t.renderRect.w
+	at := func(p int) int { return runeClass(t.copyModeRunes(p / w)[p%w]) }
+
+	p := c.y*w + c.x
+	if p == 0 {
+		return
+	}
+
+	p--
+	for p > 0 && at(p) == 0 {
+		p--
+	}
+	class := at(p)
+	for p > 0 && p%w != 0 && at(p-1) == class {
+		p--
+	}
+
+	c.x, c.y = p%w, p/w
+}
+
+// scrollbackLen is how many lines of scrollback can be browsed. The alternate screen has none
+func (t *Pane) scrollbackLen() int {
+	if t.vterm.UsingAltScreen {
+		return 0
+	}
+	return len(t.vterm.Scrollback)
+}
+
+// bufferLen is the number of lines in the pane's buffer: its scrollback and the visible screen
+func (t *Pane) bufferLen() int {
+	return t.scrollbackLen() + t.renderRect.h
+}
+
+func (t *Pane) bufferLine(y int) []render.Char {
+	n := t.scrollbackLen()
+	if y < n {
+		return t.vterm.Scrollback[y]
+	}
+	if y-n < len(t.vterm.Screen) {
+		return t.vterm.Screen[y-n]
+	}
+	return nil
+}
@@ -0,0 +1,118 @@
+package main
+
+import (
+	"testing"
+
+	"github.com/aaronjanse/3mux/render"
+	"github.com/aaronjanse/3mux/vterm"
+)
+
+const testPaneWidth = 16
+
+// newTestPane returns a pane in copy mode whose screen shows the given lines
+func newTestPane(lines ...string) *Pane {
+	screen := [][]render.Char{}
+	for _, line := range lines {
+		row := make([]render.Char, testPaneWidth)
+		for x, r := range []rune(line) {
+			row[x] = render.Char{Rune: r}
+		}
+		screen = append(screen, row)
+	}
+
+	return &Pane{
+		vterm:      &vterm.VTerm{Screen: screen},
+		renderRect: Rect{w: testPaneWidth, h: len(lines)},
+		copyMode:   &CopyMode{},
+	}
+}
+
+func TestCopyModeSelection(t *testing.T) {
+	lines := []string{"hello world", "foo bar baz", "  indented"}
+
+	tests := []struct {
+		visual           VisualMode
+		anchorX, anchorY int
+		x, y             int
+		want             string
+	}{
+		{VisualChar, 0, 0, 4, 0, "hello"},
+		{VisualChar, 6, 0, 2, 1, "world\nfoo"},
+		{VisualChar, 2, 1, 6, 0, "world\nfoo"},
+		{VisualChar, 8, 1, 3, 2, "baz\n  in"},
+		{VisualLine, 3, 0, 1, 1, "hello world\nfoo bar baz"},
+		{VisualLine, 5, 2, 0, 1, "foo bar baz\n  indented"},
+		{VisualBlock, 4, 1, 0, 0, "hello\nfoo b"},
+		{VisualBlock, 0, 2, 3, 1, "foo\n  in"},
+		{VisualBlock, 12, 0, 15, 2, "\n\n"},
+	}
+
+	for _, test := range tests {
+		pane := newTestPane(lines...)
+		c := pane.copyMode
+		c.visual = test.visual
+		c.anchorX, c.anchorY = test.anchorX, test.anchorY
+		c.x, c.y = test.x, test.y
+
+		if got := pane.copyModeSelection(); got != test.want {
+			t.Errorf("mode %d from (%d, %d) to (%d, %d): got %q, want %q",
+				test.visual, test.anchorX, test.anchorY, test.x, test.y, got, test.want)
+		}
+	}
+}
+
+func TestCopyModeWordMotions(t *testing.T) {
+	pane := newTestPane("foo.bar  baz", "qux")
+	c := pane.copyMode
+
+	forward := [][2]int{{3, 0}, {4, 0}, {9, 0}, {0, 1}, {testPaneWidth - 1, 1}}
+	for _, want := range forward {
+		from := [2]int{c.x, c.y}
+		pane.copyModeWordForward()
+		if got := [2]int{c.x, c.y}; got != want {
+			t.Errorf("w from %v: got %v, want %v", from, got, want)
+		}
+	}
+
+	c.x, c.y = 0, 1
+	backward := [][2]int{{9, 0}, {4, 0}, {3, 0}, {0, 0}, {0, 0}}
+	for _, want := range backward {
+		from := [2]int{c.x, c.y}
+		pane.copyModeWordBackward()
+		if got := [2]int{c.x, c.y}; got != want {
+			t.Errorf("b from %v: got %v, want %v", from, got, want)
+		}
+	}
+}
+
+func TestCopyModeSearch(t *testing.T) {
+	tests := []struct {
+		text     string
+		backward bool
+		x, y     int
+		wantX    int
+		wantY    int
+	}{
+		{"abc", false, 0, 0, 4, 0},
+		{"abc", false, 4, 0, 1, 2},
+		{"abc", false, 1, 2, 0, 0}, // wraps around to the top
+		{"abc", true, 0, 0, 1, 2},  // wraps around to the bottom
+		{"abc", true, 1, 2, 4, 0},
+		{"xyz", false, 0, 1, 0, 1}, // the only match is under the cursor
+		{"nope", false, 2, 1, 2, 1},
+		{"", false, 2, 1, 2, 1},
+	}
+
+	for _, test := range tests {
+		pane := newTestPane("abc abc", "xyz", " abc")
+		c := pane.copyMode
+		c.searchText = test.text
+		c.x, c.y = test.x, test.y
+
+		pane.copyModeSearch(test.backward)
+		if c.x != test.wantX || c.y != test.wantY {
+			t.Errorf("searching for %q (backward: %v) from (%d, %d): got (%d, %d), want (%d, %d)",
+				test.text, test.backward, test.x, test.y, c.x, c.y, test.wantX, test.wantY)
+		}
+	}
+}
@@ -179,7 +179,7 @@ func doDemo() {
 
 	// time.Sleep(500 * time.Millisecond)
 
-	// getSelection().getContainer().(*Pane).vterm.ChangePause <- false
+	// getSelection().getContainer().(*Pane).vterm.ChangeFreeze <- false
 	// getSelection().getContainer().(*Pane).vterm.RedrawWindow()
 
 	// // time.Sleep(5000000 * time.Millisecond)
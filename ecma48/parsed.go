@@ -65,6 +65,17 @@ type CursorMovement struct {
 	Ctrl  bool
 }
 
+// OSC (Operating System Command) is used for things like setting the window title
+type OSC struct {
+	Code int
+	Data string
+}
+
+// Paste is text pasted into the host terminal, without the bracketed paste markers around it
+type Paste struct {
+	Text string
+}
+
 // RI (Reverse Index)
 type RI struct{}
 
@@ -176,14 +187,21 @@ type ScrollDown int
 
 type ScrollUp int
 
+// MouseDown, MouseUp, and MouseDrag are for the left mouse button unless Right is set
 type MouseDown struct {
-	X, Y int
+	X, Y      int
+	Right     bool
+	Alt, Ctrl bool
 }
 
 type MouseUp struct {
-	X, Y int
+	X, Y      int
+	Right     bool
+	Alt, Ctrl bool
 }
 
 type MouseDrag struct {
-	X, Y int
+	X, Y      int
+	Right     bool
+	Alt, Ctrl bool
 }
@@ -31,6 +31,13 @@ type Parser struct {
 
 	data []rune
 
+	// osc collects an OSC string until it is terminated, possibly by an ESC \ sequence
+	osc   []rune
+	inOsc bool
+
+	// pasted collects text pasted into the host terminal until the end of the bracketed paste
+	pasted []rune
+
 	// RuneCounter is useful for detecting if the processer is lagging
 	RuneCounter uint64
 }
@@ -73,6 +80,11 @@ func (p *Parser) Parse(input *bufio.Reader, output chan<- Output) error {
 
 		p.data = append(p.data, r)
 
+		if p.state == statePaste {
+			p.statePaste(r)
+			continue
+		}
+
 		if p.keyboardMode && r == 27 {
 			switch input.Buffered() {
 			case 0:
@@ -126,6 +138,7 @@ const (
 	stateCsiEntry
 	stateCsiParam
 	stateOscString
+	statePaste
 )
 
 func (p *Parser) anywhere(r rune) {
@@ -142,8 +155,13 @@ func (p *Parser) anywhere(r rune) {
 		p.doClear()
 		p.state = stateCsiEntry
 	case 0x9C:
+		if p.inOsc {
+			p.dispatchOsc()
+		}
 		p.state = stateGround
 	case 0x9D:
+		p.osc = []rune{}
+		p.inOsc = true
 		p.state = stateOscString
 	default:
 		switch p.state {
@@ -201,6 +219,11 @@ func (p *Parser) stateGround(r rune) {
 }
 
 func (p *Parser) stateEscape(r rune) {
+	if r != '\\' {
+		// only ST (ESC \) can terminate an OSC string
+		p.inOsc = false
+	}
+
 	switch {
 	case strings.Contains("DEHMNOPVWXZ[\\]^_", string(r)):
 		p.anywhere(r + 0x40)
@@ -222,8 +245,8 @@ func (p *Parser) stateCsiEntry(r rune) {
 		p.state = stateCsiParam
 	case 0x40 <= r && r <= 0x7E:
 		p.final = r
-		p.dispatchCsi()
 		p.state = stateGround
+		p.dispatchCsi()
 	}
 }
 
@@ -233,20 +256,53 @@ func (p *Parser) stateCsiParam(r rune) {
 		p.params += string(r)
 	case 0x40 <= r && r <= 0x7E:
 		p.final = r
-		p.dispatchCsi()
 		p.state = stateGround
+		p.dispatchCsi()
 	}
 }
 
 func (p *Parser) stateOscString(r rune) {
-	// TODO: https://askubuntu.com/a/1141814
 	switch {
-	case 0x07 == r: // FIXME: this is weird
-		p.out <- p.wrap(Unrecognized("OSC"))
+	case 0x07 == r: // xterm accepts BEL in place of ST
+		p.dispatchOsc()
+		p.state = stateGround
+	default:
+		p.osc = append(p.osc, r)
+	}
+}
+
+// statePaste takes everything verbatim, including escape sequences, until the paste ends with ESC[201~
+func (p *Parser) statePaste(r rune) {
+	const end = "\x1b[201~"
+
+	p.pasted = append(p.pasted, r)
+	if n := len(p.pasted) - len(end); n >= 0 && string(p.pasted[n:]) == end {
+		text := string(p.pasted[:n])
+		p.pasted = nil
 		p.state = stateGround
+		p.out <- p.wrap(Paste{Text: text})
 	}
 }
 
+func (p *Parser) dispatchOsc() {
+	p.inOsc = false
+
+	text := string(p.osc)
+	sep := strings.Index(text, ";")
+	if sep == -1 {
+		p.out <- p.wrap(Unrecognized("OSC"))
+		return
+	}
+
+	code, err := strconv.Atoi(text[:sep])
+	if err != nil {
+		p.out <- p.wrap(Unrecognized("OSC"))
+		return
+	}
+
+	p.out <- p.wrap(OSC{Code: code, Data: text[sep+1:]})
+}
+
 func (p *Parser) doClear() {
 	p.private = 0
 	p.intermediate = ""
@@ -260,21 +316,27 @@ func (p *Parser) dispatchCsi() {
 	case "<":
 		seq := parseSemicolonNumSeq(p.params, 1)
 
-		switch seq[0] {
-		case 0:
+		// the modifier bits are the same for every kind of event
+		alt := seq[0]&8 != 0
+		ctrl := seq[0]&16 != 0
+
+		switch seq[0] &^ (8 | 16) {
+		case 0, 2: // left or right button
 			if len(seq) > 2 {
+				right := seq[0]&2 != 0
 				switch p.final {
 				case 'M':
-					p.out <- p.wrap(MouseDown{X: seq[1] - 1, Y: seq[2] - 1})
+					p.out <- p.wrap(MouseDown{X: seq[1] - 1, Y: seq[2] - 1, Right: right, Alt: alt, Ctrl: ctrl})
 				case 'm':
-					p.out <- p.wrap(MouseUp{X: seq[1] - 1, Y: seq[2] - 1})
+					p.out <- p.wrap(MouseUp{X: seq[1] - 1, Y: seq[2] - 1, Right: right, Alt: alt, Ctrl: ctrl})
 				default:
 					p.out <- p.wrap(Unrecognized("Mouse"))
 				}
 			}
-		case 32:
+		case 32, 34:
 			if len(seq) > 2 {
-				p.out <- p.wrap(MouseDrag{X: seq[1] - 1, Y: seq[2] - 1})
+				right := seq[0]&2 != 0
+				p.out <- p.wrap(MouseDrag{X: seq[1] - 1, Y: seq[2] - 1, Right: right, Alt: alt, Ctrl: ctrl})
 			}
 		case 64:
 			p.out <- p.wrap(ScrollDown(1))
@@ -403,6 +465,15 @@ func (p *Parser) dispatchCsi() {
 			p.out <- p.wrap(SCOSC{})
 		case 'u': // Restore Cursor Positon
 			p.out <- p.wrap(SCORC{})
+		case '~':
+			if p.keyboardMode && p.params == "200" {
+				// the host terminal is starting a bracketed paste
+				p.pasted = []rune{}
+				p.state = statePaste
+			} else {
+				p.out <- p.wrap(Unrecognized("CSI"))
+				log.Printf("? CSI , %s, %s", p.params, string(p.final))
+			}
 		default:
 			p.out <- p.wrap(Unrecognized("CSI"))
 			log.Printf("? CSI , %s, %s", p.params, string(p.final))
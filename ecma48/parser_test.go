@@ -0,0 +1,88 @@
+package ecma48
+
+import (
+	"bufio"
+	"io"
+	"reflect"
+	"testing"
+)
+
+// parseAll returns everything parsed from the chunks, which are read one at a time like separate writes by a terminal
+func parseAll(keyboardMode bool, chunks ...string) []Parsed {
+	reader, writer := io.Pipe()
+	go func() {
+		for _, chunk := range chunks {
+			writer.Write([]byte(chunk))
+		}
+		writer.Close()
+	}()
+
+	out := make(chan Output)
+	go func() {
+		NewParser(keyboardMode).Parse(bufio.NewReader(reader), out)
+		close(out)
+	}()
+
+	parsed := []Parsed{}
+	for o := range out {
+		parsed = append(parsed, o.Parsed)
+	}
+	return parsed
+}
+
+func TestParseOSC52(t *testing.T) {
+	tests := []struct {
+		input string
+		want  []Parsed
+	}{
+		{"\x1b]52;c;aGVsbG8=\x07", []Parsed{OSC{Code: 52, Data: "c;aGVsbG8="}}},
+		{"\x1b]52;c;aGVsbG8=\x1b\\", []Parsed{OSC{Code: 52, Data: "c;aGVsbG8="}}},
+		{"\x1b]52;;?\x07", []Parsed{OSC{Code: 52, Data: ";?"}}},
+		{"a\x1b]52;p;aGk=\x07b", []Parsed{Char{Rune: 'a'}, OSC{Code: 52, Data: "p;aGk="}, Char{Rune: 'b'}}},
+	}
+
+	for _, test := range tests {
+		got := parseAll(false, test.input)
+		if !reflect.DeepEqual(got, test.want) {
+			t.Errorf("parsing %q: got %#v, want %#v", test.input, got, test.want)
+		}
+	}
+}
+
+func TestParseBracketedPaste(t *testing.T) {
+	tests := []struct {
+		chunks []string
+		want   []Parsed
+	}{
+		{[]string{"\x1b[200~hello\x1b[201~"}, []Parsed{Paste{Text: "hello"}}},
+		{[]string{"\x1b[200~\x1b[201~"}, []Parsed{Paste{Text: ""}}},
+		{[]string{"\x1b[200~a\x1bjb\x1b[201~"}, []Parsed{Paste{Text: "a\x1bjb"}}},
+		{[]string{"\x1b[200~\x1b[A\r\n\x03\x1b[201~"}, []Parsed{Paste{Text: "\x1b[A\r\n\x03"}}},
+		{[]string{"\x1b[200~multi\nline\x1b[201~"}, []Parsed{Paste{Text: "multi\nline"}}},
+
+		// an escape on its own is usually Alt+key, but not in a paste
+		{[]string{"\x1b[200~ls", "\x1bj", "\x1b[201~"}, []Parsed{Paste{Text: "ls\x1bj"}}},
+		{[]string{"\x1b[200~", "\x1b", "[201~"}, []Parsed{Paste{Text: ""}}},
+		{[]string{"\x1b[2", "00~text\x1b[20", "1~"}, []Parsed{Paste{Text: "text"}}},
+
+		// keys before and after a paste are parsed as usual
+		{[]string{"x\x1b[200~y\x1b[201~z"}, []Parsed{Char{Rune: 'x'}, Paste{Text: "y"}, Char{Rune: 'z'}}},
+		{[]string{"\x1b[200~p\x1b[201~", "\x1bj"}, []Parsed{Paste{Text: "p"}, AltChar{Char: 'J'}}},
+	}
+
+	for _, test := range tests {
+		got := parseAll(true, test.chunks...)
+		if !reflect.DeepEqual(got, test.want) {
+			t.Errorf("parsing %q: got %#v, want %#v", test.chunks, got, test.want)
+		}
+	}
+}
+
+func TestParsePasteOnlyFromKeyboard(t *testing.T) {
+	// a program writing ESC[200~ to its pane doesn't start a paste
+	got := parseAll(false, "\x1b[200~x")
+	want := []Parsed{Unrecognized("CSI"), Char{Rune: 'x'}}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("got %#v, want %#v", got, want)
+	}
+}
@@ -0,0 +1,146 @@
+package main
+
+// toggleFloating takes the selected pane out of the tiling tree into a floating window above it,
+// or puts the focused floating pane back next to the selected tiled pane, taking it out of the scratchpad
+func toggleFloating() {
+	unzoom()
+
+	ws := root.workspaces[root.selectionIdx]
+
+	if ws.floatingFocused {
+		pane := ws.removeFloating(len(ws.floating) - 1)
+		pane.scratch = false
+		parent, _ := getSelection().getParent()
+		parent.insertContainer(pane, parent.selectionIdx+1)
+		parent.selectionIdx++
+	} else {
+		// there must always be a tiled pane
+		if len(getPanesOfSplit(ws.contents)) == 1 {
+			return
+		}
+
+		path := getSelection()
+		_, parentPath := path.getParent()
+		pane := parentPath.popContainer(path[len(path)-1]).(*Pane)
+
+		// start in the middle, a quarter the size of the workspace
+		r := ws.contents.renderRect
+		pane.renderRect = Rect{x: r.x + r.w/4, y: r.y + r.h/4, w: r.w / 2, h: r.h / 2}
+
+		ws.floating = append(ws.floating, pane)
+		ws.floatingFocused = true
+	}
+
+	ws.focusDepth = 0
+	root.simplify()
+	root.updateSelection()
+	root.refreshRenderRect()
+}
+
+// focusFloating moves focus between the floating panes and the tiled ones, like i3's `focus mode_toggle`
+func focusFloating() {
+	unzoom()
+
+	ws := root.workspaces[root.selectionIdx]
+	if len(ws.floating) == 0 {
+		return
+	}
+
+	ws.floatingFocused = !ws.floatingFocused
+	root.updateSelection()
+	root.refreshRenderRect()
+}
+
+// raiseFloating brings a floating pane to the top and focuses it
+func raiseFloating(idx int) {
+	ws := root.workspaces[root.selectionIdx]
+	pane := ws.floating[idx]
+	ws.floating = append(append(ws.floating[:idx], ws.floating[idx+1:]...), pane)
+	ws.floatingFocused = true
+
+	root.updateSelection()
+	root.refreshRenderRect()
+}
+
+// findFloatingAtCoords returns the index of the topmost floating pane whose window (including its border)
+// covers the given coordinates, or -1 if there is none
+func findFloatingAtCoords(x, y int) int {
+	ws := root.workspaces[root.selectionIdx]
+	if ws.zoomed {
+		return -1
+	}
+
+	for idx := len(ws.floating) - 1; idx >= 0; idx-- {
+		r := ws.floating[idx].renderRect
+		if r.x-1 <= x && x <= r.x+r.w && r.y-1 <= y && y <= r.y+r.h {
+			return idx
+		}
+	}
+	return -1
+}
+
+// moveFloating shifts the focused floating pane by the given number of cells
+func moveFloating(dx, dy int) {
+	ws := root.workspaces[root.selectionIdx]
+	pane := ws.floating[len(ws.floating)-1]
+	pane.renderRect.x += dx
+	pane.renderRect.y += dy
+
+	root.refreshRenderRect()
+}
+
+// resizeFloating grows or shrinks the focused floating pane from its bottom right corner
+func resizeFloating(dw, dh int) {
+	ws := root.workspaces[root.selectionIdx]
+	pane := ws.floating[len(ws.floating)-1]
+	pane.renderRect.w += dw
+	pane.renderRect.h += dh
+
+	root.refreshRenderRect()
+}
+
+// getFloatingShift is how far a keypress moves or resizes a floating pane in a direction
+func getFloatingShift(d Direction) (int, int) {
+	switch d {
+	case Up:
+		return 0, -1
+	case Down:
+		return 0, 1
+	case Left:
+		return -2, 0
+	case Right:
+		return 2, 0
+	}
+	return 0, 0
+}
+
+// clampFloating keeps a floating pane and its border within area, shrinking it if needed
+func clampFloating(r, area Rect) Rect {
+	if r.w > area.w-2 {
+		r.w = area.w - 2
+	}
+	if r.h > area.h-2 {
+		r.h = area.h - 2
+	}
+	if r.w < 2 {
+		r.w = 2
+	}
+	if r.h < 2 {
+		r.h = 2
+	}
+
+	if r.x+r.w+1 > area.x+area.w {
+		r.x = area.x + area.w - r.w - 1
+	}
+	if r.y+r.h+1 > area.y+area.h {
+		r.y = area.y + area.h - r.h - 1
+	}
+	if r.x < area.x+1 {
+		r.x = area.x + 1
+	}
+	if r.y < area.y+1 {
+		r.y = area.y + 1
+	}
+
+	return r
+}
@@ -3,7 +3,9 @@ module github.com/aaronjanse/3mux
 go 1.14
 
 require (
+	github.com/BurntSushi/toml v0.3.0
 	github.com/kr/pty v1.1.8
 	github.com/mattn/go-runewidth v0.0.9
 	golang.org/x/crypto v0.0.0-20200406173513-056763e48d71
+	gopkg.in/yaml.v2 v2.4.0
 )
@@ -18,11 +18,31 @@ var demoTextDuration = 1000 * time.Millisecond
 // handleInput puts the input through a series of switches and seive functions.
 // When something acts on the event, we stop passing it downstream
 func handleInput(human string, obj ecma48.Output) {
-	defer func() {
-		if config.statusBar {
-			debug(root.serialize())
+	defer refreshStatusBar()
+
+	// pasted text never triggers bindings, even if it holds something like Alt+J
+	if x, ok := obj.Parsed.(ecma48.Paste); ok {
+		handlePaste(x.Text)
+		return
+	}
+
+	if pendingClipboard != nil && !isMouseEvent(obj) {
+		answerClipboardRequest(obj)
+		return
+	}
+
+	if statusError != "" {
+		statusError = ""
+		if !config.statusBar {
+			// the error was drawn over the bottom of the panes
+			root.refreshRenderRect()
 		}
-	}()
+	}
+
+	if bufferChooser != nil {
+		handleBufferChooserInput(obj)
+		return
+	}
 
 	if demoMode {
 		renderer.DemoText = human
@@ -57,7 +77,7 @@ func handleInput(human string, obj ecma48.Output) {
 	}
 
 	// log.Printf("%q %+v", obj.Raw, obj.Parsed)
-	t := getSelection().getContainer().(*Pane)
+	t := root.workspaces[root.selectionIdx].focusedPane()
 
 	switch x := obj.Parsed.(type) {
 	case ecma48.CursorMovement:
@@ -78,6 +98,19 @@ func handleInput(human string, obj ecma48.Output) {
 
 }
 
+// handleCommand runs an operation sent over the socket, e.g. by `3mux msg`
+func handleCommand(op string) error {
+	defer refreshStatusBar()
+
+	if err := executeOperationCode(op); err != nil {
+		return err
+	}
+
+	root.simplify()
+	root.refreshRenderRect()
+	return nil
+}
+
 var tmuxMode = false
 
 func seiveTmuxEvents(human string, obj ecma48.Output) bool {
@@ -89,45 +122,32 @@ func seiveTmuxEvents(human string, obj ecma48.Output) bool {
 	if tmuxMode {
 		switch string(obj.Raw) {
 		case "%":
-			pane := getSelection().getContainer().(*Pane)
-
-			parent, _ := getSelection().getParent()
-			parent.elements[parent.selectionIdx].contents = &Split{
-				verticallyStacked: true,
-				selectionIdx:      0,
-				elements: []Node{Node{
-					size:     1,
-					contents: pane,
-				}},
-			}
-
-			root.AddPane()
-			root.simplify()
-			root.refreshRenderRect()
+			splitPane(true)
 		case "\"":
-			pane := getSelection().getContainer().(*Pane)
-
-			parent, _ := getSelection().getParent()
-			parent.elements[parent.selectionIdx].contents = &Split{
-				verticallyStacked: false,
-				selectionIdx:      0,
-				elements: []Node{Node{
-					size:     1,
-					contents: pane,
-				}},
-			}
-
-			root.AddPane()
-			root.simplify()
-			root.refreshRenderRect()
+			splitPane(false)
+		case "d":
+			detach()
+		case "[":
+			enterCopyMode()
+		case "]":
+			paste()
+		case "=":
+			chooseBuffer()
+		case "\x0f": // Ctrl+O
+			rotateSplit(false)
+		case " ":
+			nextLayout()
 		case "{":
 			moveWindow(Left)
 		case "}":
 			moveWindow(Right)
 		case "o": // next pane
+			unzoom()
 			path := getSelection()
 			oldTerm := path.getContainer().(*Pane)
 			oldTerm.selected = false
+			root.workspaces[root.selectionIdx].focusDepth = 0
+			root.workspaces[root.selectionIdx].floatingFocused = false
 			for {
 				if len(path) == 1 {
 					// select the first terminal
@@ -142,7 +162,6 @@ func seiveTmuxEvents(human string, obj ecma48.Output) bool {
 						}
 						if done {
 							break
-							root.simplify()
 						}
 					}
 					break
@@ -169,14 +188,15 @@ func seiveTmuxEvents(human string, obj ecma48.Output) bool {
 				}
 			}
 			// select the new Term
-			newTerm := getSelection().getContainer().(*Pane)
-			newTerm.selected = true
-			newTerm.vterm.RefreshCursor()
+			root.updateSelection()
 			root.refreshRenderRect()
 		case ";": // prev pane
+			unzoom()
 			path := getSelection()
 			oldTerm := path.getContainer().(*Pane)
 			oldTerm.selected = false
+			root.workspaces[root.selectionIdx].focusDepth = 0
+			root.workspaces[root.selectionIdx].floatingFocused = false
 			for {
 				if len(path) == 1 {
 					// select the first terminal
@@ -217,9 +237,7 @@ func seiveTmuxEvents(human string, obj ecma48.Output) bool {
 				}
 			}
 			// select the new Term
-			newTerm := getSelection().getContainer().(*Pane)
-			newTerm.selected = true
-			newTerm.vterm.RefreshCursor()
+			root.updateSelection()
 			root.refreshRenderRect()
 		}
 		tmuxMode = false
@@ -251,23 +269,132 @@ func seiveResizeEvents(human string, obj ecma48.Output) bool {
 var mouseDownPath Path
 var mouseDownX, mouseDownY int
 
+// A floatingDrag is an alt+drag that moves a floating pane, or resizes it with the right button
+type floatingDrag struct {
+	resize       bool
+	lastX, lastY int
+}
+
+// activeDrag is set while a floating pane is being dragged
+var activeDrag *floatingDrag
+
+// A textDrag is a click in a pane that may become a selection of its text
+type textDrag struct {
+	pane      *Pane
+	x, y      int // where the button was pressed
+	selecting bool
+}
+
+// activeTextDrag is set while the left button is held down in a pane
+var activeTextDrag *textDrag
+
+// clicks counts repeated clicks in the same spot, for double and triple clicks
+var clicks int
+var lastClickX, lastClickY int
+var lastClickTime time.Time
+
+// startTextDrag handles the left button being pressed in a pane. A double click selects a word, and a triple click a line
+func startTextDrag(x, y int) {
+	pane := paneAtCoords(x, y)
+	if pane == nil {
+		activeTextDrag = nil
+		return
+	}
+
+	if x == lastClickX && y == lastClickY && time.Since(lastClickTime) < 500*time.Millisecond && clicks < 3 {
+		clicks++
+	} else {
+		clicks = 1
+	}
+	lastClickX, lastClickY = x, y
+	lastClickTime = time.Now()
+
+	activeTextDrag = &textDrag{pane: pane, x: x, y: y}
+	switch clicks {
+	case 2:
+		pane.selectWordAt(x, y)
+		activeTextDrag.selecting = true
+	case 3:
+		pane.startMouseSelection(x, y, VisualLine)
+		activeTextDrag.selecting = true
+	}
+}
+
+// isMouseEvent returns whether the input came from the mouse rather than the keyboard
+func isMouseEvent(obj ecma48.Output) bool {
+	switch x := obj.Parsed.(type) {
+	case ecma48.MouseDown, ecma48.MouseUp, ecma48.MouseDrag, ecma48.ScrollUp, ecma48.ScrollDown:
+		return true
+	case ecma48.Unrecognized:
+		return x == "Mouse"
+	}
+	return false
+}
+
 // seiveMouseEvents processes mouse events and returns true if the data should *not* be passed downstream
 func seiveMouseEvents(human string, obj ecma48.Output) bool {
 	switch ev := obj.Parsed.(type) {
 	case ecma48.MouseDown:
-		root.SelectAtCoords(ev.X, ev.Y)
+		if idx := findFloatingAtCoords(ev.X, ev.Y); idx != -1 {
+			raiseFloating(idx)
+			if ev.Alt {
+				activeDrag = &floatingDrag{resize: ev.Right, lastX: ev.X, lastY: ev.Y}
+			} else if !ev.Right && !ev.Ctrl {
+				startTextDrag(ev.X, ev.Y)
+			}
+		} else if ev.Right {
+			// do nothing
+		} else if ev.Ctrl {
+			// ctrl+click swaps the selected pane with the one clicked
+			path := findPaneAtCoords(ev.X, ev.Y)
+			if path != nil && !root.workspaces[root.selectionIdx].zoomed {
+				swapPanes(getSelection(), path)
+			}
+		} else {
+			root.SelectAtCoords(ev.X, ev.Y)
+			startTextDrag(ev.X, ev.Y)
+		}
 		mouseDownX = ev.X
 		mouseDownY = ev.Y
 	case ecma48.MouseUp:
-		root.DragBorder(mouseDownX, mouseDownY, ev.X, ev.Y)
+		if activeDrag != nil {
+			activeDrag = nil
+		} else if activeTextDrag != nil && activeTextDrag.selecting {
+			// releasing the button copies the selection
+			activeTextDrag.pane.finishMouseSelection()
+			activeTextDrag = nil
+		} else if !ev.Right {
+			activeTextDrag = nil
+			root.DragBorder(mouseDownX, mouseDownY, ev.X, ev.Y)
+		}
 	case ecma48.MouseDrag:
-		// do nothing
+		if activeDrag != nil {
+			dx, dy := ev.X-activeDrag.lastX, ev.Y-activeDrag.lastY
+			if activeDrag.resize {
+				resizeFloating(dx, dy)
+			} else {
+				moveFloating(dx, dy)
+			}
+			activeDrag.lastX, activeDrag.lastY = ev.X, ev.Y
+		} else if activeTextDrag != nil && !ev.Right {
+			if !activeTextDrag.selecting {
+				activeTextDrag.pane.startMouseSelection(activeTextDrag.x, activeTextDrag.y, VisualChar)
+				activeTextDrag.selecting = true
+			}
+			activeTextDrag.pane.extendMouseSelection(ev.X, ev.Y)
+		}
 	case ecma48.ScrollUp:
-		t := getSelection().getContainer().(*Pane)
+		t := root.workspaces[root.selectionIdx].focusedPane()
 		t.vterm.ScrollbackDown()
+		if t.copyMode != nil {
+			t.followCopyMode()
+		}
 	case ecma48.ScrollDown:
-		t := getSelection().getContainer().(*Pane)
+		t := root.workspaces[root.selectionIdx].focusedPane()
 		t.vterm.ScrollbackUp()
+		if t.copyMode != nil {
+			t.followCopyMode()
+		}
 	default:
 		return false
 	}
@@ -0,0 +1,68 @@
+package main
+
+import (
+	"bufio"
+	"io"
+	"io/ioutil"
+	"os"
+	"testing"
+
+	"github.com/aaronjanse/3mux/ecma48"
+	"github.com/aaronjanse/3mux/vterm"
+)
+
+func TestPasteDoesNotTriggerBindings(t *testing.T) {
+	oldRoot, oldConfig := root, config
+	defer func() {
+		root, config = oldRoot, oldConfig
+	}()
+
+	ptmxReader, ptmx, err := os.Pipe()
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer ptmxReader.Close()
+
+	// a pane whose program turned on bracketed paste, with nothing on screen to draw
+	pane := &Pane{ptmx: ptmx, vterm: &vterm.VTerm{IsPaused: true, BracketedPaste: true}}
+	root = Universe{workspaces: []*Workspace{newWorkspace(1, pane)}}
+
+	triggered := 0
+	config.statusBar = false
+	config.bindings = map[string]func(){"Alt+J": func() { triggered++ }}
+
+	// the paste holds what Alt+J sends, then Alt+J is pressed for real
+	reader, writer := io.Pipe()
+	go func() {
+		for _, chunk := range []string{"\x1b[200~ls", "\x1bj", "\x1b[201~", "\x1bj"} {
+			writer.Write([]byte(chunk))
+		}
+		writer.Close()
+	}()
+
+	out := make(chan ecma48.Output)
+	go func() {
+		ecma48.NewParser(true).Parse(bufio.NewReader(reader), out)
+		close(out)
+	}()
+	for obj := range out {
+		human := ""
+		if x, ok := obj.Parsed.(ecma48.AltChar); ok {
+			human = "Alt+" + string(x.Char)
+		}
+		handleInput(human, obj)
+	}
+
+	ptmx.Close()
+	written, err := ioutil.ReadAll(ptmxReader)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if want := "\x1b[200~ls\x1bj\x1b[201~"; string(written) != want {
+		t.Errorf("the pane was sent %q, want %q", written, want)
+	}
+	if triggered != 1 {
+		t.Errorf("Alt+J was triggered %d times, want once", triggered)
+	}
+}
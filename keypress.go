@@ -1,15 +1,11 @@
 package main
 
 import (
-	"bufio"
 	"fmt"
-	"log"
 	"os"
 	"os/exec"
-	"os/signal"
 	"strconv"
 	"strings"
-	"syscall"
 
 	"github.com/aaronjanse/3mux/ecma48"
 	"golang.org/x/crypto/ssh/terminal"
@@ -17,7 +13,7 @@ import (
 
 var oldState *terminal.State
 
-// Shutdown cleans up the terminal state
+// Shutdown cleans up the host terminal state after a client detaches
 func Shutdown() {
 	if oldState != nil {
 		terminal.Restore(0, oldState)
@@ -25,6 +21,7 @@ func Shutdown() {
 
 	fmt.Print("\x1b[?1002l")
 	fmt.Print("\x1b[?1006l")
+	fmt.Print("\x1b[?2004l")
 	fmt.Print("\x1b[?1049l")
 }
 
@@ -37,47 +34,26 @@ func humanify(r rune) string {
 	}
 }
 
-// Listen is a blocking function that indefinitely listens for keypresses.
+// Listen is a blocking function that indefinitely listens for keypresses from attached clients.
 // When it detects a keypress, it passes on to the callback a human-readable interpretation of the event (e.g. Alt+Shift+Up) along with the raw string of text received by the terminal.
+// Requests from the socket are run in between keypresses.
 func Listen(callback func(human string, obj ecma48.Output)) {
-	var err error
-	oldState, err = terminal.MakeRaw(0)
-	if err != nil {
-		log.Fatal(err)
-	}
-
-	fmt.Print("\x1b[?1049h")
-	fmt.Print("\x1b[?1006h")
-	fmt.Print("\x1b[?1002h")
-	fmt.Print("\x1b[?1l")
-
-	defer Shutdown()
-
-	go func() {
-		for {
-			c := make(chan os.Signal, 1)
-			signal.Notify(c, syscall.SIGWINCH)
-			<-c
-			w, h, _ := GetTermSize()
-			resize(w, h)
+	for {
+		var next clientInput
+		select {
+		case fn := <-server.commands:
+			server.activeClient = nil
+			fn()
+			continue
+		case next = <-server.inputs:
 		}
-	}()
-
-	stdin := make(chan ecma48.Output, 64)
-
-	parser := ecma48.NewParser(true)
 
-	go parser.Parse(bufio.NewReader(os.Stdin), stdin)
-
-	for {
-		next := <-stdin
+		server.activeClient = next.client
 
 		humanCode := ""
 		switch x := next.Parsed.(type) {
 		case ecma48.CtrlChar:
 			if x.Char == 'Q' {
-				close(stdin)
-				os.Stdin.Close()
 				return
 			}
 			humanCode = fmt.Sprintf("Ctrl+%s", humanify(x.Char))
@@ -107,7 +83,7 @@ func Listen(callback func(human string, obj ecma48.Output)) {
 			}
 		}
 
-		callback(humanCode, next)
+		callback(humanCode, next.Output)
 	}
 }
 
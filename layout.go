@@ -0,0 +1,584 @@
+package main
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"log"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"sort"
+	"strings"
+
+	"gopkg.in/yaml.v2"
+)
+
+// The types below are how a session's layout is saved to disk so `3mux -restore` can rebuild it.
+// Layout files given to `3mux -l` are converted to them too.
+
+type savedUniverse struct {
+	Focused    int              `json:"focused"` // number of the visible workspace
+	Workspaces []savedWorkspace `json:"workspaces"`
+	Scratchpad []savedFloating  `json:"scratchpad,omitempty"` // hidden panes, which keep their size but not their position
+}
+
+type savedWorkspace struct {
+	Num    int        `json:"num"`
+	Zoomed bool       `json:"zoomed"`
+	Root   savedSplit `json:"root"`
+
+	Floating        []savedFloating `json:"floating,omitempty"` // the last is on top
+	FloatingFocused bool            `json:"floatingFocused,omitempty"`
+}
+
+// A savedFloating is a floating pane along with where it's drawn
+type savedFloating struct {
+	X       int       `json:"x"`
+	Y       int       `json:"y"`
+	W       int       `json:"w"`
+	H       int       `json:"h"`
+	Scratch bool      `json:"scratch,omitempty"` // shown from the scratchpad, see showScratchpad
+	Pane    savedPane `json:"pane"`
+}
+
+type savedSplit struct {
+	Vertical  bool        `json:"vertical"`
+	Mode      string      `json:"mode,omitempty"` // tabbed or stacked, if not a regular split
+	Selection int         `json:"selection"`
+	Children  []savedNode `json:"children"`
+}
+
+// A savedNode holds either a split or a pane
+type savedNode struct {
+	Size  float32     `json:"size"`
+	Split *savedSplit `json:"split,omitempty"`
+	Pane  *savedPane  `json:"pane,omitempty"`
+}
+
+type savedPane struct {
+	Command []string `json:"command"`
+	Cwd     string   `json:"cwd"`
+	Env     []string `json:"env,omitempty"`
+}
+
+// sessionFile is where a session's layout is saved by default
+func sessionFile(session string) string {
+	dir := os.Getenv("XDG_DATA_HOME")
+	if dir == "" {
+		dir = filepath.Join(os.Getenv("HOME"), ".local", "share")
+	}
+	return filepath.Join(dir, "3mux", "sessions", session+".json")
+}
+
+// saveLayout writes the window tree along with each pane's command and working directory to path
+func saveLayout(path string) error {
+	layout := savedUniverse{
+		Focused:    root.workspaces[root.selectionIdx].num,
+		Workspaces: []savedWorkspace{},
+	}
+	for _, ws := range root.workspaces {
+		saved := savedWorkspace{
+			Num:             ws.num,
+			Zoomed:          ws.zoomed,
+			Root:            saveSplit(ws.contents),
+			FloatingFocused: ws.floatingFocused,
+		}
+		for _, pane := range ws.floating {
+			saved.Floating = append(saved.Floating, saveFloating(pane))
+		}
+		layout.Workspaces = append(layout.Workspaces, saved)
+	}
+	for _, pane := range root.scratchpad {
+		layout.Scratchpad = append(layout.Scratchpad, saveFloating(pane))
+	}
+
+	data, err := json.MarshalIndent(layout, "", "  ")
+	if err != nil {
+		return err
+	}
+
+	if err := os.MkdirAll(filepath.Dir(path), 0700); err != nil {
+		return err
+	}
+	return ioutil.WriteFile(path, append(data, '\n'), 0600)
+}
+
+// autosaveLayout saves the layout to the session file before the server exits
+func autosaveLayout() {
+	if len(root.workspaces) == 0 {
+		return
+	}
+	if err := saveLayout(sessionFile(server.name)); err != nil {
+		log.Printf("While saving layout: %s", err.Error())
+	}
+}
+
+func saveSplit(s *Split) savedSplit {
+	saved := savedSplit{
+		Vertical:  s.verticallyStacked,
+		Selection: s.selectionIdx,
+		Children:  []savedNode{},
+	}
+	if s.mode != Tiled {
+		saved.Mode = s.mode.String()
+	}
+
+	for _, e := range s.elements {
+		node := savedNode{Size: e.size}
+		switch c := e.contents.(type) {
+		case *Split:
+			child := saveSplit(c)
+			node.Split = &child
+		case *Pane:
+			pane := savePane(c)
+			node.Pane = &pane
+		}
+		saved.Children = append(saved.Children, node)
+	}
+
+	return saved
+}
+
+func saveFloating(t *Pane) savedFloating {
+	r := t.renderRect
+	return savedFloating{X: r.x, Y: r.y, W: r.w, H: r.h, Scratch: t.scratch, Pane: savePane(t)}
+}
+
+func savePane(t *Pane) savedPane {
+	return savedPane{
+		Command: t.cmd.Args,
+		Cwd:     t.cwd(),
+		Env:     t.env,
+	}
+}
+
+// loadLayout reads a layout saved by saveLayout, checking that it can be rebuilt
+func loadLayout(path string) (savedUniverse, error) {
+	var layout savedUniverse
+
+	data, err := ioutil.ReadFile(path)
+	if err != nil {
+		return layout, err
+	}
+	if err := json.Unmarshal(data, &layout); err != nil {
+		return layout, fmt.Errorf("%s: %s", path, err.Error())
+	}
+
+	if err := layout.validate(); err != nil {
+		return layout, fmt.Errorf("%s: %s", path, err.Error())
+	}
+	return layout, nil
+}
+
+func (l savedUniverse) validate() error {
+	if len(l.Workspaces) == 0 {
+		return errors.New("no workspaces")
+	}
+
+	nums := map[int]bool{}
+	for _, ws := range l.Workspaces {
+		if ws.Num < 1 {
+			return fmt.Errorf("invalid workspace: %d", ws.Num)
+		} else if nums[ws.Num] {
+			return fmt.Errorf("duplicate workspace: %d", ws.Num)
+		}
+		nums[ws.Num] = true
+
+		if err := ws.Root.validate(); err != nil {
+			return fmt.Errorf("workspace %d: %s", ws.Num, err.Error())
+		}
+
+		if ws.FloatingFocused && len(ws.Floating) == 0 {
+			return fmt.Errorf("workspace %d: no floating pane to focus", ws.Num)
+		}
+		for _, f := range ws.Floating {
+			if err := f.validate(); err != nil {
+				return fmt.Errorf("workspace %d: %s", ws.Num, err.Error())
+			}
+		}
+	}
+
+	for _, f := range l.Scratchpad {
+		if err := f.validate(); err != nil {
+			return fmt.Errorf("scratchpad: %s", err.Error())
+		}
+	}
+
+	if !nums[l.Focused] {
+		return fmt.Errorf("focused workspace %d doesn't exist", l.Focused)
+	}
+	return nil
+}
+
+func (s savedSplit) validate() error {
+	if len(s.Children) == 0 {
+		return errors.New("empty split")
+	}
+	if s.Selection < 0 || s.Selection >= len(s.Children) {
+		return fmt.Errorf("selection %d is out of range", s.Selection)
+	}
+	if _, err := getSplitModeFromName(s.Mode); err != nil {
+		return err
+	}
+
+	for _, n := range s.Children {
+		if n.Size <= 0 {
+			return fmt.Errorf("invalid size: %v", n.Size)
+		}
+
+		if n.Split != nil && n.Pane == nil {
+			if err := n.Split.validate(); err != nil {
+				return err
+			}
+		} else if n.Pane != nil && n.Split == nil {
+			if err := n.Pane.validate(); err != nil {
+				return err
+			}
+		} else {
+			return errors.New("each child must be either a split or a pane")
+		}
+	}
+	return nil
+}
+
+func (f savedFloating) validate() error {
+	if f.W <= 0 || f.H <= 0 {
+		return fmt.Errorf("invalid floating pane size: %dx%d", f.W, f.H)
+	}
+	return f.Pane.validate()
+}
+
+func (p savedPane) validate() error {
+	if len(p.Command) > 0 {
+		if _, err := exec.LookPath(p.Command[0]); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// startingLayout reads the layout given by -restore or -l, returning nil if there is none
+func startingLayout() (*savedUniverse, error) {
+	var layout savedUniverse
+	var err error
+	switch {
+	case *restoreFile != "" && *layoutPath != "":
+		return nil, errors.New("can't use -restore and -l together")
+	case *restoreFile != "":
+		layout, err = loadLayout(*restoreFile)
+	case *layoutPath != "":
+		layout, err = loadLayoutFile(*layoutPath)
+	default:
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	return &layout, nil
+}
+
+// restoreLayout replaces the window tree with one built from a saved layout
+func restoreLayout(l savedUniverse) {
+	root = Universe{workspaces: []*Workspace{}}
+
+	for _, saved := range l.Workspaces {
+		ws := &Workspace{
+			num:             saved.Num,
+			contents:        restoreSplit(saved.Root),
+			zoomed:          saved.Zoomed,
+			floatingFocused: saved.FloatingFocused,
+		}
+		for _, f := range saved.Floating {
+			ws.floating = append(ws.floating, restoreFloating(f))
+		}
+		root.addWorkspace(ws)
+	}
+	for _, f := range l.Scratchpad {
+		hideScratchpad(restoreFloating(f))
+	}
+
+	// the caller lays everything out once the renderer is ready
+	root.selectionIdx = root.findWorkspace(l.Focused)
+	root.workspaces[root.selectionIdx].setPause(false)
+	root.updateSelection()
+}
+
+// getSplitModeFromName reads the mode of a saved split, where no name means a regular split
+func getSplitModeFromName(name string) (SplitMode, error) {
+	if name == "" {
+		return Tiled, nil
+	}
+	for _, mode := range []SplitMode{Tiled, Tabbed, Stacked} {
+		if name == mode.String() {
+			return mode, nil
+		}
+	}
+	return Tiled, fmt.Errorf("invalid mode: %s", name)
+}
+
+func restoreSplit(saved savedSplit) *Split {
+	mode, _ := getSplitModeFromName(saved.Mode) // checked by validate
+	s := &Split{
+		verticallyStacked: saved.Vertical,
+		mode:              mode,
+		selectionIdx:      saved.Selection,
+		elements:          []Node{},
+	}
+
+	var total float32
+	for _, n := range saved.Children {
+		total += n.Size
+	}
+
+	for _, n := range saved.Children {
+		var contents Container
+		if n.Split != nil {
+			contents = restoreSplit(*n.Split)
+		} else {
+			contents = restorePane(*n.Pane)
+		}
+
+		// sizes are scaled so they add up to one
+		s.elements = append(s.elements, Node{size: n.Size / total, contents: contents})
+	}
+
+	return s
+}
+
+func restoreFloating(saved savedFloating) *Pane {
+	pane := restorePane(saved.Pane)
+	pane.scratch = saved.Scratch
+	// clamped to the screen once it's laid out
+	pane.renderRect = Rect{x: saved.X, y: saved.Y, w: saved.W, h: saved.H}
+	return pane
+}
+
+func restorePane(saved savedPane) *Pane {
+	dir := saved.Cwd
+	if info, err := os.Stat(dir); dir != "" && (err != nil || !info.IsDir()) {
+		log.Printf("Can't restore pane in %q, using the default directory instead", dir)
+		dir = ""
+	}
+	return newTermWithCommand(false, dir, saved.Command, saved.Env)
+}
+
+// A layoutFile describes the workspaces and panes to start a session with, e.g. `3mux -l dev.yaml`
+type layoutFile struct {
+	Root       string            `yaml:"root"` // relative cwds start here, defaulting to the layout file's directory
+	Env        map[string]string `yaml:"env"`  // given to every pane
+	Workspaces []layoutWorkspace `yaml:"workspaces"`
+}
+
+type layoutWorkspace struct {
+	Num        int `yaml:"num"` // defaults to the workspace's position in the list
+	layoutNode `yaml:",inline"`
+}
+
+// A layoutNode is a split if it has panes of its own, otherwise it's a pane
+type layoutNode struct {
+	Size  float32 `yaml:"size"` // defaults to an even share of what the other panes leave
+	Focus bool    `yaml:"focus"`
+
+	// splits
+	Split string       `yaml:"split"` // horizontal (the default), vertical, tabbed, or stacked
+	Panes []layoutNode `yaml:"panes"`
+
+	// panes
+	Command layoutCommand     `yaml:"command"`
+	Cwd     string            `yaml:"cwd"`
+	Env     map[string]string `yaml:"env"`
+}
+
+// A layoutCommand is either a list of arguments or a string to be run by the user's shell
+type layoutCommand []string
+
+func (c *layoutCommand) UnmarshalYAML(unmarshal func(interface{}) error) error {
+	var args []string
+	if err := unmarshal(&args); err == nil {
+		*c = args
+		return nil
+	}
+
+	var script string
+	if err := unmarshal(&script); err != nil {
+		return err
+	}
+	*c = layoutCommand{getShellPath(), "-c", script}
+	return nil
+}
+
+// loadLayoutFile reads a YAML layout file, converting it to the format used by saved layouts
+func loadLayoutFile(path string) (savedUniverse, error) {
+	var file layoutFile
+
+	data, err := ioutil.ReadFile(path)
+	if err != nil {
+		return savedUniverse{}, err
+	}
+	if err := yaml.UnmarshalStrict(data, &file); err != nil {
+		return savedUniverse{}, fmt.Errorf("%s: %s", path, err.Error())
+	}
+
+	absPath, err := filepath.Abs(path)
+	if err != nil {
+		return savedUniverse{}, err
+	}
+
+	layout, err := file.convert(filepath.Dir(absPath))
+	if err != nil {
+		return savedUniverse{}, fmt.Errorf("%s: %s", path, err.Error())
+	}
+	if err := layout.validate(); err != nil {
+		return savedUniverse{}, fmt.Errorf("%s: %s", path, err.Error())
+	}
+	return layout, nil
+}
+
+func (f layoutFile) convert(dir string) (savedUniverse, error) {
+	if len(f.Workspaces) == 0 {
+		return savedUniverse{}, errors.New("no workspaces")
+	}
+
+	root := dir
+	if f.Root != "" {
+		root = expandPath(f.Root, dir)
+	}
+
+	layout := savedUniverse{Workspaces: []savedWorkspace{}}
+	hasFocus := false
+	for idx, ws := range f.Workspaces {
+		num := ws.Num
+		if num == 0 {
+			num = idx + 1
+		}
+
+		// a workspace with a single pane still needs a split to hold it
+		node := ws.layoutNode
+		if len(node.Panes) == 0 {
+			node = layoutNode{Panes: []layoutNode{node}}
+		}
+
+		converted, focus, err := node.convert(root, f.Env)
+		if err != nil {
+			return savedUniverse{}, fmt.Errorf("workspace %d: %s", num, err.Error())
+		}
+
+		if idx == 0 || focus {
+			if focus && hasFocus {
+				return savedUniverse{}, errors.New("more than one pane has focus")
+			}
+			hasFocus = hasFocus || focus
+			layout.Focused = num
+		}
+
+		layout.Workspaces = append(layout.Workspaces, savedWorkspace{
+			Num:  num,
+			Root: *converted.Split,
+		})
+	}
+
+	return layout, nil
+}
+
+// convert turns the node into a savedNode, also reporting whether it holds the focused pane
+func (n layoutNode) convert(root string, env map[string]string) (savedNode, bool, error) {
+	if len(n.Panes) == 0 {
+		vars := map[string]string{}
+		for k, v := range env {
+			vars[k] = v
+		}
+		for k, v := range n.Env {
+			vars[k] = v
+		}
+
+		pane := savedPane{
+			Command: n.Command,
+			Cwd:     root,
+			Env:     []string{},
+		}
+		if n.Cwd != "" {
+			pane.Cwd = expandPath(n.Cwd, root)
+		}
+		for k, v := range vars {
+			pane.Env = append(pane.Env, k+"="+v)
+		}
+		sort.Strings(pane.Env)
+
+		return savedNode{Size: n.Size, Pane: &pane}, n.Focus, nil
+	}
+
+	if len(n.Command) > 0 || n.Cwd != "" || len(n.Env) > 0 {
+		return savedNode{}, false, errors.New("a split can't have a command, cwd, or env")
+	}
+
+	split := savedSplit{Children: []savedNode{}}
+	switch n.Split {
+	case "", "horizontal":
+		split.Vertical = false
+	case "vertical":
+		split.Vertical = true
+	case "tabbed":
+		split.Mode = Tabbed.String()
+	case "stacked":
+		split.Vertical = true
+		split.Mode = Stacked.String()
+	default:
+		return savedNode{}, false, fmt.Errorf("invalid split: %s", n.Split)
+	}
+
+	// panes without a size evenly share whatever is left over
+	var sized float32
+	unsized := 0
+	for _, child := range n.Panes {
+		if child.Size < 0 {
+			return savedNode{}, false, fmt.Errorf("invalid size: %v", child.Size)
+		} else if child.Size == 0 {
+			unsized++
+		} else {
+			sized += child.Size
+		}
+	}
+	fill := float32(1)
+	if unsized > 0 && sized > 0 {
+		fill = (1 - sized) / float32(unsized)
+		if fill <= 0 {
+			return savedNode{}, false, errors.New("sizes add up to more than 1")
+		}
+	}
+
+	hasFocus := n.Focus
+	for idx, child := range n.Panes {
+		converted, focus, err := child.convert(root, env)
+		if err != nil {
+			return savedNode{}, false, err
+		}
+		if converted.Size == 0 {
+			converted.Size = fill
+		}
+
+		if focus {
+			if hasFocus {
+				return savedNode{}, false, errors.New("more than one pane has focus")
+			}
+			hasFocus = true
+			split.Selection = idx
+		}
+
+		split.Children = append(split.Children, converted)
+	}
+
+	return savedNode{Size: n.Size, Split: &split}, hasFocus, nil
+}
+
+// expandPath resolves ~ and paths relative to dir
+func expandPath(path, dir string) string {
+	if path == "~" || strings.HasPrefix(path, "~/") {
+		path = filepath.Join(os.Getenv("HOME"), path[1:])
+	}
+	if !filepath.IsAbs(path) {
+		path = filepath.Join(dir, path)
+	}
+	return path
+}
@@ -6,8 +6,12 @@ import (
 	"io/ioutil"
 	"log"
 	"os"
+	"os/signal"
 	runtimeDebug "runtime/debug"
 	"runtime/pprof"
+	"strconv"
+	"strings"
+	"syscall"
 
 	"github.com/aaronjanse/3mux/ecma48"
 	"github.com/aaronjanse/3mux/render"
@@ -24,16 +28,32 @@ var renderer *render.Renderer
 
 var cpuprofile = flag.String("cpuprofile", "", "write cpu profile to file")
 var writeLogs = flag.Bool("log", false, "write logs to ./logs.txt")
+var restoreFile = flag.String("restore", "", "start a new session with a layout saved by saveLayout")
+var layoutPath = flag.String("l", "", "start a new session with the layout described by a YAML file")
+var serverSession = flag.String("server", "", "run as the background server for the named session (used internally)")
 
 func main() {
+	flag.Parse()
+
+	if *serverSession != "" {
+		runServer(*serverSession)
+		return
+	}
+
+	if err := runCommand(flag.Args()); err != nil {
+		fmt.Fprintln(os.Stderr, "3mux:", err)
+		os.Exit(1)
+	}
+}
+
+// runServer owns the session's window tree and shells, serving clients until the user quits
+func runServer(session string) {
 	defer func() {
 		if r := recover(); r != nil {
 			fatalShutdownNow("main.go\n" + r.(error).Error())
 		}
 	}()
 
-	flag.Parse()
-
 	// setup logging
 	if *writeLogs {
 		f, err := os.OpenFile("logs.txt", os.O_RDWR|os.O_CREATE|os.O_APPEND, 0666)
@@ -57,45 +77,62 @@ func main() {
 	}
 
 	var err error
-	termW, termH, err = GetTermSize()
+	config, err = loadConfig()
+	if err != nil {
+		log.Fatalf("While loading config: %s", err.Error())
+	}
+
+	listener, err := listenSocket(socketPath(session))
 	if err != nil {
-		log.Fatalf("While getting terminal size: %s", err.Error())
+		log.Fatalf("While creating socket: %s", err.Error())
 	}
+	server = newServer(session, listener)
 
-	renderer = render.NewRenderer()
+	// until a client attaches and tells us its real size
+	termW, termH = 80, 24
+
+	renderer = render.NewRenderer(server)
 	go renderer.ListenToQueue()
 
-	root = Universe{
-		workspaces: []*Workspace{
-			&Workspace{
-				contents: &Split{
-					verticallyStacked: false,
-					selectionIdx:      0,
-					elements: []Node{
-						Node{
-							size:     1,
-							contents: newTerm(true),
-						},
-					}},
-				doFullscreen: false,
+	if layout, err := startingLayout(); err != nil {
+		log.Fatalf("While loading layout: %s", err.Error())
+	} else if layout != nil {
+		restoreLayout(*layout)
+	} else {
+		root = Universe{
+			workspaces: []*Workspace{
+				newWorkspace(1, newTerm(true)),
 			},
-		},
-		selectionIdx: 0,
+			selectionIdx: 0,
+		}
 	}
 
+	defer server.close("")
 	defer root.kill()
 
 	resize(termW, termH)
 
-	if config.statusBar {
-		debug(root.serialize())
-	}
+	refreshStatusBar()
 
 	if demoMode {
 		go doDemo()
 	}
 
+	go func() {
+		c := make(chan os.Signal, 1)
+		signal.Notify(c, syscall.SIGHUP)
+		for range c {
+			// the main loop reads the config, so swap it in between keypresses
+			server.run(reloadConfig)
+		}
+	}()
+
+	go server.acceptClients()
+
 	Listen(handleInput)
+
+	// Ctrl+Q was pressed
+	autosaveLayout()
 }
 
 func resize(w, h int) {
@@ -119,41 +156,135 @@ func shutdownNow() {
 	if *cpuprofile != "" {
 		pprof.StopCPUProfile()
 	}
-	Shutdown()
+	if server != nil {
+		server.close("")
+	}
 	os.Exit(0)
 }
 
+// fatalShutdownNow exits the server, sending the error report to attached clients for display
 func fatalShutdownNow(where string) {
 	if *cpuprofile != "" {
 		pprof.StopCPUProfile()
 	}
-	Shutdown()
-	fmt.Println("Error during:", where)
-	fmt.Println("Tiling state:", root.serialize())
-	fmt.Println(string(runtimeDebug.Stack()))
-	fmt.Println()
-	fmt.Println("Please submit a bug report with this stack trace to https://github.com/aaronjanse/3mux/issues")
+
+	var report strings.Builder
+	fmt.Fprintln(&report, "Error during:", where)
+	fmt.Fprintln(&report, "Tiling state:", root.serialize())
+	fmt.Fprintln(&report, string(runtimeDebug.Stack()))
+	fmt.Fprintln(&report)
+	fmt.Fprint(&report, "Please submit a bug report with this stack trace to https://github.com/aaronjanse/3mux/issues")
+
+	log.Println(report.String())
+	if server != nil {
+		server.close(report.String())
+	}
 	os.Exit(0)
 }
 
 var resizeMode bool
 
-func getDirectionFromString(s string) Direction {
+func getDirectionFromString(s string) (Direction, error) {
 	switch s {
 	case "Up":
-		return Up
+		return Up, nil
 	case "Down":
-		return Down
+		return Down, nil
 	case "Left":
-		return Left
+		return Left, nil
 	case "Right":
-		return Right
+		return Right, nil
 	default:
-		panic(fmt.Errorf("invalid direction: %v", s))
+		return 0, fmt.Errorf("invalid direction: %v", s)
 	}
 }
 
-func debug(s string) {
+func getWorkspaceFromString(s string) (int, error) {
+	n, err := strconv.Atoi(s)
+	if err != nil || n < 1 {
+		return 0, fmt.Errorf("invalid workspace: %v", s)
+	}
+	return n, nil
+}
+
+// getOrientationFromString tells whether a split should stack its panes vertically
+func getOrientationFromString(s string) (bool, error) {
+	switch s {
+	case "Vertical":
+		return true, nil
+	case "Horizontal":
+		return false, nil
+	default:
+		return false, fmt.Errorf("invalid orientation: %v", s)
+	}
+}
+
+func getSplitModeFromString(s string) (SplitMode, error) {
+	switch s {
+	case "Split":
+		return Tiled, nil
+	case "Tabbed":
+		return Tabbed, nil
+	case "Stacked":
+		return Stacked, nil
+	default:
+		return Tiled, fmt.Errorf("invalid layout: %v", s)
+	}
+}
+
+// getPaneIDFromString parses a pane id, which may be written like `%3`
+func getPaneIDFromString(s string) (int, error) {
+	id, err := strconv.Atoi(strings.TrimPrefix(s, "%"))
+	if err != nil {
+		return 0, fmt.Errorf("invalid pane id: %v", s)
+	}
+	return id, nil
+}
+
+// getPaneFromString finds a pane by id, wherever it is
+func getPaneFromString(s string) (*PaneLocation, error) {
+	id, err := getPaneIDFromString(s)
+	if err != nil {
+		return nil, err
+	}
+	loc := findPane(id)
+	if loc == nil {
+		return nil, fmt.Errorf("no pane with id %d", id)
+	}
+	return loc, nil
+}
+
+// statusError is shown in place of the status bar until the next keypress
+var statusError string
+
+func refreshStatusBar() {
+	if pendingClipboard != nil {
+		drawStatusText(clipboardPrompt(), ecma48.Color{
+			ColorMode: ecma48.ColorBit3Bright,
+			Code:      3,
+		})
+	} else if statusError != "" {
+		drawStatusText(statusError, ecma48.Color{
+			ColorMode: ecma48.ColorBit3Bright,
+			Code:      1,
+		})
+	} else if config.statusBar {
+		// lead with the focused pane's id since the rest may not fit
+		ws := root.workspaces[root.selectionIdx]
+		zoom := ""
+		if ws.zoomed {
+			zoom = "Z "
+		}
+		mark := ""
+		if findPane(markedPaneID) != nil {
+			mark = fmt.Sprintf("(marked %%%d) ", markedPaneID)
+		}
+		debug(fmt.Sprintf("%%%d %s%s%s", ws.focusedPane().id, zoom, mark, root.serialize()))
+	}
+}
+
+// drawStatusText fills the bottom line of the screen with the given text
+func drawStatusText(s string, bg ecma48.Color) {
 	for i := 0; i < termW; i++ {
 		r := ' '
 		if i < len(s) {
@@ -166,10 +297,7 @@ func debug(s string) {
 				X: i,
 				Y: termH - 1,
 				Style: render.Style{
-					Bg: ecma48.Color{
-						ColorMode: ecma48.ColorBit3Bright,
-						Code:      2,
-					},
+					Bg: bg,
 					Fg: ecma48.Color{
 						ColorMode: ecma48.ColorBit3Normal,
 						Code:      0,
@@ -179,6 +307,13 @@ func debug(s string) {
 		}
 		renderer.HandleCh(ch)
 	}
+}
+
+func debug(s string) {
+	drawStatusText(s, ecma48.Color{
+		ColorMode: ecma48.ColorBit3Bright,
+		Code:      2,
+	})
 
 	if resizeMode {
 		resizeText := "RESIZE"
@@ -4,8 +4,8 @@ import (
 	"bufio"
 	"errors"
 	"fmt"
+	"io"
 	"log"
-	"math/rand"
 	"os"
 	"os/exec"
 	runtimeDebug "runtime/debug"
@@ -34,6 +34,7 @@ type Pane struct {
 	vterm *vterm.VTerm
 
 	id         int
+	env        []string // extra environment variables given to the command, e.g. by a layout file
 	selected   bool
 	renderRect Rect
 
@@ -46,6 +47,21 @@ type Pane struct {
 	searchDirection       SearchDirection
 
 	Dead bool
+
+	// exited is set when the command exits while remain-on-exit is on, keeping the pane on screen
+	exited bool
+	// remainInput feeds the vterm of an exited pane until it is killed or respawned
+	remainInput *io.PipeWriter
+	killed      bool
+
+	// clipboard is what happens when the pane's program copies text, see setClipboard
+	clipboard ClipboardPolicy
+
+	// scratch is set once the pane has been moved to the scratchpad, see moveToScratchpad
+	scratch bool
+
+	// copyMode is set while the pane's scrollback is browsed with vi keys, see enterCopyMode
+	copyMode *CopyMode
 }
 
 func getShellPath() string {
@@ -75,14 +91,42 @@ func getShellPath() string {
 	return ""
 }
 
+// nextPaneID is the id given to the next Pane, so ids are never reused within a session
+var nextPaneID = 0
+
 func newTerm(selected bool) *Pane {
-	cmd := exec.Command(getShellPath())
-	cmd.Env = append(os.Environ(), "TERM=xterm-256color") // FIXME we should decide whether we want 256color in $TERM
+	return newTermWithCommand(selected, "", nil, nil)
+}
+
+// newTermWithCommand runs args in dir with extra environment variables like `KEY=value`.
+// The command defaults to the user's shell and dir to the server's working directory.
+func newTermWithCommand(selected bool, dir string, args []string, env []string) *Pane {
+	id := nextPaneID
+	nextPaneID++
+
+	if len(args) == 0 {
+		args = []string{getShellPath()}
+	}
+
 	t := &Pane{
-		id:       rand.Intn(10),
+		id:       id,
+		env:      env,
 		selected: selected,
-		cmd:      cmd,
 	}
+	t.start(dir, args)
+
+	return t
+}
+
+// start runs the pane's command in a fresh pty and vterm
+func (t *Pane) start(dir string, args []string) {
+	t.cmd = exec.Command(args[0], args[1:]...)
+	t.cmd.Dir = dir
+	t.cmd.Env = append(os.Environ(),
+		"TERM=xterm-256color", // FIXME we should decide whether we want 256color in $TERM
+		fmt.Sprintf("THREEMUX_PANE=%%%d", t.id),
+	)
+	t.cmd.Env = append(t.cmd.Env, t.env...)
 
 	ptmx, err := pty.Start(t.cmd)
 	if err != nil {
@@ -96,7 +140,10 @@ func newTerm(selected bool) *Pane {
 		}
 	}
 
-	t.vterm = vterm.NewVTerm(renderer, parentSetCursor)
+	t.vterm = vterm.NewVTerm(renderer, parentSetCursor, func(text string) {
+		// the clipboard policy and paste buffers belong to the main loop
+		server.post(func() { t.setClipboard(text) })
+	})
 	go func() {
 		defer func() {
 			if r := recover(); r != nil {
@@ -105,31 +152,71 @@ func newTerm(selected bool) *Pane {
 		}()
 
 		t.vterm.ProcessStream(bufio.NewReader(t.ptmx))
+		t.cmd.Wait()
 
-		// FIXME: only supports one workspace
-		if t.selected {
-			root.workspaces[root.selectionIdx].doFullscreen = false
-			root.workspaces[root.selectionIdx].contents.setPause(false)
-		}
+		// the tree belongs to the main loop, which hands back what's left to show if the pane remains
+		remaining := make(chan io.Reader, 1)
+		server.post(func() {
+			if config.remainOnExit && !t.killed {
+				remaining <- t.remain()
+				return
+			}
+			close(remaining)
 
-		t.Dead = true
-		removeTheDead([]int{root.selectionIdx})
+			t.Dead = true
+			root.removeTheDead()
 
-		if len(root.workspaces[root.selectionIdx].contents.elements) == 0 {
-			shutdownNow()
-		} else {
-			// deselect the old Term
-			newTerm := getSelection().getContainer().(*Pane)
-			newTerm.selected = true
-			newTerm.softRefresh()
-			newTerm.vterm.RefreshCursor()
-
-			root.simplify()
-			root.refreshRenderRect()
+			if len(root.workspaces) == 0 {
+				shutdownNow()
+			} else {
+				root.simplify()
+				root.updateSelection()
+				root.refreshRenderRect()
+			}
+		})
+		if output, ok := <-remaining; ok {
+			t.vterm.ProcessStream(bufio.NewReader(output))
 		}
 	}()
+}
 
-	return t
+// remain keeps an exited pane on screen. It returns the rest of the pane's output:
+// its exit status, then anything written until the pane is killed or respawned.
+func (t *Pane) remain() io.Reader {
+	status := t.cmd.ProcessState.String() // e.g. "signal: killed"
+	if t.cmd.ProcessState.Exited() {
+		status = fmt.Sprintf("status %d", t.cmd.ProcessState.ExitCode())
+	}
+	banner := fmt.Sprintf("\r\n\x1b[7m[exited: %s]\x1b[0m", status)
+
+	reader, writer := io.Pipe()
+	t.remainInput = writer
+	t.exited = true
+
+	return io.MultiReader(strings.NewReader(banner), reader)
+}
+
+// respawn restarts the original command of an exited pane in its place
+func (t *Pane) respawn() {
+	// the old output only reaches its end once it's unfrozen
+	if t.copyMode != nil {
+		t.exitCopyMode()
+	}
+	if t.searchMode {
+		t.toggleSearch()
+	}
+	t.remainInput.Close()
+	t.ptmx.Close()
+
+	paused := t.vterm.IsPaused
+	layer := t.vterm.Layer
+	t.exited = false
+	t.start(t.cmd.Dir, t.cmd.Args)
+	t.vterm.SetPaused(paused)
+	t.vterm.Layer = layer
+
+	r := t.renderRect
+	t.setRenderRect(r.x, r.y, r.w, r.h)
 }
 
 func (t *Pane) UpdateSelection(selected bool) {
@@ -140,7 +227,9 @@ func (t *Pane) UpdateSelection(selected bool) {
 }
 
 func (t *Pane) handleStdin(in string) {
-	if t.searchMode && t.searchResultsMode {
+	if t.copyMode != nil {
+		t.handleCopyModeInput(in)
+	} else if t.searchMode && t.searchResultsMode {
 		switch in[0] { // FIXME ignores extra chars
 		case 'n': // next
 			t.searchDirection = SearchDown
@@ -201,7 +290,7 @@ func (t *Pane) handleStdin(in string) {
 		t.searchPos = 0
 		t.doSearch()
 		t.displayStatusText(t.searchText)
-	} else {
+	} else if !t.exited {
 		t.vterm.ScrollbackReset()
 		_, err := t.ptmx.Write([]byte(in))
 		if err != nil {
@@ -308,18 +397,11 @@ func (t *Pane) toggleSearch() {
 	t.searchMode = !t.searchMode
 
 	if t.searchMode {
-		t.vterm.ChangePause <- true
+		t.setFrozen(true)
 		t.searchBackupScrollPos = t.vterm.ScrollbackPos
 		t.searchResultsMode = false
 		t.searchDirection = SearchUp
 
-		// FIXME hacky way to wait for full control of screen section
-		timer := time.NewTimer(time.Millisecond * 5)
-		select {
-		case <-timer.C:
-			timer.Stop()
-		}
-
 		lastLineIsBlank := true
 		lastLine := t.vterm.Screen[len(t.vterm.Screen)-2]
 		for _, c := range lastLine {
@@ -354,7 +436,21 @@ func (t *Pane) toggleSearch() {
 			t.vterm.Scrollback = t.vterm.Scrollback[:len(t.vterm.Scrollback)-1]
 		}
 		t.vterm.RedrawWindow()
-		t.vterm.ChangePause <- false
+		t.setFrozen(false)
+	}
+}
+
+// setFrozen stops or resumes processing the pane's output, giving us full control of its screen while frozen
+func (t *Pane) setFrozen(frozen bool) {
+	t.vterm.ChangeFreeze <- frozen
+
+	if frozen {
+		// FIXME hacky way to wait for full control of screen section
+		timer := time.NewTimer(time.Millisecond * 5)
+		select {
+		case <-timer.C:
+			timer.Stop()
+		}
 	}
 }
 
@@ -382,7 +478,7 @@ func (t *Pane) displayStatusText(s string) {
 				},
 			},
 		}
-		renderer.HandleCh(ch)
+		renderer.HandleLayeredCh(ch, t.vterm.Layer)
 	}
 }
 
@@ -410,6 +506,11 @@ func (t *Pane) clearStatusText() {
 }
 
 func (t *Pane) kill() {
+	t.killed = true
+	if t.remainInput != nil {
+		t.remainInput.Close()
+	}
+
 	t.vterm.Kill()
 	// FIXME: handle error
 	t.ptmx.Close()
@@ -417,12 +518,35 @@ func (t *Pane) kill() {
 	t.cmd.Process.Kill()
 }
 
+// cwd returns the working directory of the pane's shell, or "" if it can't be found.
+// It prefers what the shell reports with OSC 7, falling back to /proc on systems that have it.
+func (t *Pane) cwd() string {
+	if t.vterm.Cwd != "" {
+		return t.vterm.Cwd
+	}
+
+	dir, err := os.Readlink(fmt.Sprintf("/proc/%d/cwd", t.cmd.Process.Pid))
+	if err != nil {
+		return ""
+	}
+	return dir
+}
+
+// newPaneDir is where new panes start: the focused pane's working directory, if it still exists
+func newPaneDir() string {
+	dir := root.workspaces[root.selectionIdx].focusedPane().cwd()
+	if info, err := os.Stat(dir); err != nil || !info.IsDir() {
+		return ""
+	}
+	return dir
+}
+
 func (t *Pane) setPause(pause bool) {
-	t.vterm.ChangePause <- pause
+	t.vterm.SetPaused(pause)
 }
 
 func (t *Pane) serialize() string {
-	out := fmt.Sprintf("Term[%d,%d %dx%d]", t.renderRect.x, t.renderRect.y, t.renderRect.w, t.renderRect.h)
+	out := fmt.Sprintf("Term%%%d[%d,%d %dx%d]", t.id, t.renderRect.x, t.renderRect.y, t.renderRect.w, t.renderRect.h)
 	if t.selected {
 		return out + "*"
 	}
@@ -442,6 +566,10 @@ func (t *Pane) setRenderRect(x, y, w, h int) {
 	t.resizeShell(w, h)
 
 	t.softRefresh()
+
+	if t.copyMode != nil {
+		t.drawCopyMode()
+	}
 }
 
 func (t *Pane) resizeShell(w, h int) {
@@ -465,7 +593,13 @@ func (t *Pane) getRenderRect() Rect {
 
 func (t *Pane) softRefresh() {
 	// only selected Panes get the special highlight color
+	style := render.Style{}
 	if t.selected {
-		drawSelectionBorder(t.renderRect)
+		style = selectionStyle
+	}
+
+	// floating panes always need a border to stand out from the panes under them
+	if t.selected || t.vterm.Layer > 0 {
+		drawBorder(t.renderRect, style, t.vterm.Layer)
 	}
 }
@@ -0,0 +1,122 @@
+package main
+
+import (
+	"fmt"
+	"math"
+)
+
+// A Preset is a well-known arrangement of a workspace's panes, like tmux's layouts
+type Preset int
+
+// presets, in the order nextLayout cycles through them
+const (
+	EvenHorizontal Preset = iota // side by side
+	EvenVertical                 // on top of each other
+	MainVertical                 // the first pane on the left, the rest stacked on the right
+	TiledGrid                    // a grid, row by row
+	numPresets
+)
+
+func getPresetFromString(s string) (Preset, error) {
+	switch s {
+	case "EvenHorizontal":
+		return EvenHorizontal, nil
+	case "EvenVertical":
+		return EvenVertical, nil
+	case "MainVertical":
+		return MainVertical, nil
+	case "Tiled":
+		return TiledGrid, nil
+	default:
+		return 0, fmt.Errorf("invalid layout: %v", s)
+	}
+}
+
+// selectLayout rearranges the panes of the current workspace, keeping the same pane selected
+func selectLayout(p Preset) {
+	unzoom()
+
+	ws := root.workspaces[root.selectionIdx]
+	selected := ws.selectedPane()
+
+	panes := []Container{}
+	for _, pane := range getPanesOfSplit(ws.contents) {
+		panes = append(panes, pane)
+	}
+
+	var contents *Split
+	switch p {
+	case EvenHorizontal:
+		contents = newEvenSplit(false, panes)
+	case EvenVertical:
+		contents = newEvenSplit(true, panes)
+	case MainVertical:
+		contents = newEvenSplit(false, panes[:1])
+		if len(panes) > 1 {
+			contents.insertContainer(newEvenContainer(true, panes[1:]), 1)
+		}
+	case TiledGrid:
+		cols := int(math.Ceil(math.Sqrt(float64(len(panes)))))
+		rows := []Container{}
+		for len(panes) > cols {
+			rows = append(rows, newEvenContainer(false, panes[:cols]))
+			panes = panes[cols:]
+		}
+		rows = append(rows, newEvenContainer(false, panes))
+		contents = newEvenSplit(true, rows)
+	}
+
+	contents.renderRect = ws.contents.renderRect
+	ws.contents = contents
+	ws.preset = p
+	ws.focusDepth = 0
+
+	// panes that were behind a tab are shown again
+	ws.setPause(false)
+
+	setSelection(findTiledPane(selected.id))
+	root.simplify()
+	root.refreshRenderRect()
+}
+
+// nextLayout applies the preset after the last one used on this workspace
+func nextLayout() {
+	ws := root.workspaces[root.selectionIdx]
+	selectLayout((ws.preset + 1) % numPresets)
+}
+
+// newEvenContainer is like newEvenSplit, but a lone container isn't wrapped in a split
+func newEvenContainer(verticallyStacked bool, contents []Container) Container {
+	if len(contents) == 1 {
+		return contents[0]
+	}
+	return newEvenSplit(verticallyStacked, contents)
+}
+
+func newEvenSplit(verticallyStacked bool, contents []Container) *Split {
+	s := &Split{verticallyStacked: verticallyStacked}
+	for _, c := range contents {
+		s.elements = append(s.elements, Node{
+			size:     1 / float32(len(contents)),
+			contents: c,
+		})
+	}
+	return s
+}
+
+// equalize undoes resizing by giving every pane of the current workspace an even share of its split
+func equalize() {
+	unzoom()
+
+	root.workspaces[root.selectionIdx].contents.equalize()
+	root.refreshRenderRect()
+}
+
+func (s *Split) equalize() {
+	for idx := range s.elements {
+		s.elements[idx].size = 1 / float32(len(s.elements))
+		if child, ok := s.elements[idx].contents.(*Split); ok {
+			child.equalize()
+		}
+	}
+}
@@ -0,0 +1,83 @@
+package main
+
+import (
+	"encoding/binary"
+	"io"
+	"os"
+	"path/filepath"
+	"strconv"
+)
+
+// msgType identifies the kind of a message sent over a session socket
+type msgType byte
+
+// messages exchanged between a client and the server
+const (
+	// client -> server
+	msgAttach  msgType = iota // payload: host terminal width & height
+	msgResize                 // payload: host terminal width & height
+	msgInput                  // payload: raw bytes read from the host terminal
+	msgInfo                   // asks for a one-line description of the session
+	msgKill                   // kills the session's shells and server
+	msgCommand                // payload: an operation to run, e.g. `moveSelection(Left)`
+	msgTree                   // asks for the layout as JSON
+	msgBuffer                 // payload: name of a paste buffer to send back, or empty for the most recent
+
+	// server -> client
+	msgOutput // payload: rendered diff to print to the host terminal
+	msgDetach // the client should restore the host terminal and exit
+	msgExit   // the server is shutting down; payload: text to show the user
+	msgReply  // payload: response to a request
+	msgError  // payload: why a request failed
+)
+
+// writeMsg sends a message as a type byte, a big-endian uint32 length, then the payload
+func writeMsg(w io.Writer, t msgType, payload []byte) error {
+	buf := make([]byte, 5, 5+len(payload))
+	buf[0] = byte(t)
+	binary.BigEndian.PutUint32(buf[1:], uint32(len(payload)))
+	_, err := w.Write(append(buf, payload...))
+	return err
+}
+
+// readMsg blocks until a full message has been read
+func readMsg(r io.Reader) (msgType, []byte, error) {
+	header := make([]byte, 5)
+	if _, err := io.ReadFull(r, header); err != nil {
+		return 0, nil, err
+	}
+
+	payload := make([]byte, binary.BigEndian.Uint32(header[1:]))
+	if _, err := io.ReadFull(r, payload); err != nil {
+		return 0, nil, err
+	}
+
+	return msgType(header[0]), payload, nil
+}
+
+func encodeSize(w, h int) []byte {
+	buf := make([]byte, 4)
+	binary.BigEndian.PutUint16(buf[0:], uint16(w))
+	binary.BigEndian.PutUint16(buf[2:], uint16(h))
+	return buf
+}
+
+func decodeSize(buf []byte) (int, int) {
+	if len(buf) < 4 {
+		return 0, 0
+	}
+	return int(binary.BigEndian.Uint16(buf[0:])), int(binary.BigEndian.Uint16(buf[2:]))
+}
+
+// socketDir returns the directory holding one Unix socket per session
+func socketDir() string {
+	if runtimeDir := os.Getenv("XDG_RUNTIME_DIR"); runtimeDir != "" {
+		return filepath.Join(runtimeDir, "3mux")
+	}
+	return filepath.Join(os.TempDir(), "3mux-"+strconv.Itoa(os.Getuid()))
+}
+
+// socketPath returns the location of the named session's Unix socket
+func socketPath(session string) string {
+	return filepath.Join(socketDir(), session)
+}
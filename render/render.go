@@ -2,6 +2,7 @@ package render
 
 import (
 	"fmt"
+	"io"
 	"log"
 	"strings"
 	"sync"
@@ -15,12 +16,18 @@ import (
 type Renderer struct {
 	w, h int
 
+	// out is where rendered diffs are written (e.g. attached clients)
+	out io.Writer
+
 	writingMutex  *sync.Mutex
 	pendingScreen [][]Char
 	currentScreen [][]Char
 
 	highlights [][]bool
 
+	// layers holds the highest layer covering each cell, hiding what lower layers draw there
+	layers [][]int
+
 	drawingCursor Cursor
 	restingCursor Cursor
 
@@ -46,9 +53,10 @@ type Char struct {
 	Style
 }
 
-// NewRenderer returns an initialized Renderer
-func NewRenderer() *Renderer {
+// NewRenderer returns an initialized Renderer that writes its output to out
+func NewRenderer(out io.Writer) *Renderer {
 	return &Renderer{
+		out:           out,
 		writingMutex:  &sync.Mutex{},
 		currentScreen: [][]Char{},
 		pendingScreen: [][]Char{},
@@ -83,9 +91,45 @@ func expandBuffer(buffer [][]Char, w, h int) [][]Char {
 	return buffer
 }
 
-// HandleCh places a PositionedChar in the pending screen buffer
+// A Rect is an area of the screen
+type Rect struct {
+	X, Y, W, H int
+}
+
+// SetLayers stacks areas above the base layer, e.g. floating windows. rects[0] is layer 1, rects[1] is layer 2, etc.
+func (r *Renderer) SetLayers(rects []Rect) {
+	r.writingMutex.Lock()
+	defer r.writingMutex.Unlock()
+
+	r.layers = make([][]int, r.h+1)
+	for y := range r.layers {
+		r.layers[y] = make([]int, r.w+1)
+	}
+
+	for idx, rect := range rects {
+		for y := rect.Y; y < rect.Y+rect.H; y++ {
+			for x := rect.X; x < rect.X+rect.W; x++ {
+				if 0 <= y && y < len(r.layers) && 0 <= x && x < len(r.layers[y]) {
+					r.layers[y][x] = idx + 1
+				}
+			}
+		}
+	}
+}
+
+// HandleCh places a PositionedChar in the pending screen buffer, on the base layer
 func (r *Renderer) HandleCh(ch PositionedChar) {
+	r.HandleLayeredCh(ch, 0)
+}
+
+// HandleLayeredCh is like HandleCh, but the char is dropped if it's covered by a higher layer (see SetLayers)
+func (r *Renderer) HandleLayeredCh(ch PositionedChar, layer int) {
 	r.writingMutex.Lock()
+	if ch.Y < len(r.layers) && ch.X < len(r.layers[ch.Y]) && r.layers[ch.Y][ch.X] > layer {
+		r.writingMutex.Unlock()
+		return
+	}
+
 	if ch.Rune == 0 {
 		ch.Rune = ' '
 	}
@@ -142,7 +186,7 @@ func (r *Renderer) ListenToQueue() {
 		if len(diffStr) > 0 {
 			// fmt.Print("\033[?25l") // hide cursor
 
-			fmt.Print(diffStr)
+			fmt.Fprint(r.out, diffStr)
 			// log.Printf("RENDER: %+q\n", diffStr)
 
 			if len(r.DemoText) > 0 {
@@ -194,7 +238,7 @@ func (r *Renderer) ListenToQueue() {
 					r.drawingCursor = newCursor
 				}
 
-				fmt.Print(demoTextDiff.String())
+				fmt.Fprint(r.out, demoTextDiff.String())
 			}
 
 			// fmt.Print("\033[?25h") // show cursor
@@ -202,7 +246,7 @@ func (r *Renderer) ListenToQueue() {
 
 		if r.drawingCursor != r.restingCursor {
 			delta := deltaMarkup(r.drawingCursor, r.restingCursor)
-			fmt.Print(delta)
+			fmt.Fprint(r.out, delta)
 			r.drawingCursor = r.restingCursor
 		}
 
@@ -227,6 +271,11 @@ func (r *Renderer) SetCursor(x, y int) {
 	}
 }
 
+// Passthrough writes an escape sequence straight to the host terminal, e.g. to set its clipboard
+func (r *Renderer) Passthrough(s string) {
+	fmt.Fprint(r.out, s)
+}
+
 // Debug prints the given text to the status bar
 func (r *Renderer) Debug(s string) {
 	for i, ch := range s {
@@ -247,9 +296,9 @@ func (r *Renderer) GetRune(x, y int) rune {
 // HardRefresh force clears all cached chars. Used for handling terminal resize
 func (r *Renderer) HardRefresh() {
 	log.Println("HARD REFRESH")
-	fmt.Print("\033[2J")
-	fmt.Print("\033[0m")
-	fmt.Print("\033[H")
+	fmt.Fprint(r.out, "\033[2J")
+	fmt.Fprint(r.out, "\033[0m")
+	fmt.Fprint(r.out, "\033[H")
 	r.drawingCursor = Cursor{}
 	for y := range r.currentScreen {
 		for x := range r.currentScreen[y] {
@@ -0,0 +1,94 @@
+package main
+
+// moveToScratchpad hides the focused pane away from every workspace, like i3's `move scratchpad`.
+// Its command keeps running, and showScratchpad brings it back as a floating pane.
+func moveToScratchpad() {
+	unzoom()
+
+	ws := root.workspaces[root.selectionIdx]
+
+	var pane *Pane
+	if ws.floatingFocused {
+		pane = ws.removeFloating(len(ws.floating) - 1)
+	} else {
+		// there must always be a pane to show
+		if len(root.workspaces) == 1 && len(ws.floating) == 0 && len(getPanesOfSplit(ws.contents)) == 1 {
+			return
+		}
+
+		path := getSelection()
+		_, parentPath := path.getParent()
+		pane = parentPath.popContainer(path[len(path)-1]).(*Pane)
+
+		// shown as large as a newly floated pane
+		r := ws.contents.renderRect
+		pane.renderRect.w = r.w / 2
+		pane.renderRect.h = r.h / 2
+	}
+
+	hideScratchpad(pane)
+	ws.focusDepth = 0
+
+	if !ws.refill() {
+		root.removeWorkspace(root.selectionIdx)
+		return
+	}
+
+	root.simplify()
+	root.updateSelection()
+	root.refreshRenderRect()
+}
+
+// showScratchpad toggles the scratchpad like i3's `scratchpad show`: a focused scratchpad pane is hidden again,
+// one shown on this workspace is raised, and otherwise the oldest hidden one is shown in the middle of the workspace
+func showScratchpad() {
+	unzoom()
+
+	ws := root.workspaces[root.selectionIdx]
+
+	if ws.floatingFocused && ws.focusedPane().scratch {
+		hideScratchpad(ws.removeFloating(len(ws.floating) - 1))
+		root.updateSelection()
+		root.refreshRenderRect()
+		return
+	}
+
+	for idx := len(ws.floating) - 1; idx >= 0; idx-- {
+		if ws.floating[idx].scratch {
+			raiseFloating(idx)
+			return
+		}
+	}
+
+	if len(root.scratchpad) > 0 {
+		showFromScratchpad(0)
+	}
+}
+
+// showFromScratchpad takes a pane out of the scratchpad and shows it floating in the middle of the workspace, focused
+func showFromScratchpad(idx int) {
+	ws := root.workspaces[root.selectionIdx]
+
+	pane := root.scratchpad[idx]
+	root.scratchpad = append(root.scratchpad[:idx], root.scratchpad[idx+1:]...)
+
+	r := ws.contents.renderRect
+	pane.renderRect.x = r.x + (r.w-pane.renderRect.w)/2
+	pane.renderRect.y = r.y + (r.h-pane.renderRect.h)/2
+	pane.setPause(false)
+
+	ws.floating = append(ws.floating, pane)
+	ws.floatingFocused = true
+
+	root.updateSelection()
+	root.refreshRenderRect()
+}
+
+// hideScratchpad puts a pane that was taken out of its workspace into the scratchpad.
+// It keeps reading its command's output, but stops drawing it.
+func hideScratchpad(pane *Pane) {
+	pane.scratch = true
+	pane.setPause(true)
+	pane.UpdateSelection(false)
+	root.scratchpad = append(root.scratchpad, pane)
+}
@@ -0,0 +1,286 @@
+package main
+
+import (
+	"bufio"
+	"encoding/json"
+	"fmt"
+	"io"
+	"log"
+	"net"
+	"os"
+	"path/filepath"
+	"sync"
+	"time"
+
+	"github.com/aaronjanse/3mux/ecma48"
+)
+
+// A client is a host terminal attached to the server
+type client struct {
+	conn net.Conn
+
+	// writeMutex keeps messages from interleaving on the socket
+	writeMutex sync.Mutex
+
+	// input feeds this client's keyboard parser
+	input *io.PipeWriter
+}
+
+func (c *client) send(t msgType, payload []byte) error {
+	c.writeMutex.Lock()
+	defer c.writeMutex.Unlock()
+	return writeMsg(c.conn, t, payload)
+}
+
+// clientInput is a parsed keypress along with the client that sent it
+type clientInput struct {
+	client *client
+	ecma48.Output
+}
+
+// Server owns the window tree and its shells, streaming rendered output to every attached client
+type Server struct {
+	name     string
+	created  time.Time
+	listener net.Listener
+
+	mutex   sync.Mutex
+	clients []*client
+
+	// inputs merges the keypresses of all clients
+	inputs chan clientInput
+
+	// requests from the socket are run between keypresses so they never see a half-modified tree
+	commands chan func()
+
+	// activeClient is the client whose keypress is currently being handled
+	activeClient *client
+}
+
+var server *Server
+
+// listenSocket creates the socket at path, cleaning up after servers that died without removing it
+func listenSocket(path string) (net.Listener, error) {
+	if err := os.MkdirAll(filepath.Dir(path), 0700); err != nil {
+		return nil, err
+	}
+
+	if conn, err := net.Dial("unix", path); err == nil {
+		conn.Close()
+		return nil, fmt.Errorf("a server is already listening on %s", path)
+	}
+	os.Remove(path)
+
+	return net.Listen("unix", path)
+}
+
+func newServer(name string, listener net.Listener) *Server {
+	return &Server{
+		name:     name,
+		created:  time.Now(),
+		listener: listener,
+		clients:  []*client{},
+		inputs:   make(chan clientInput, 64),
+		commands: make(chan func()),
+	}
+}
+
+// acceptClients is a blocking function that handles incoming connections until the listener is closed
+func (s *Server) acceptClients() {
+	for {
+		conn, err := s.listener.Accept()
+		if err != nil {
+			return
+		}
+		go s.handleConn(conn)
+	}
+}
+
+func (s *Server) handleConn(conn net.Conn) {
+	t, payload, err := readMsg(conn)
+	if err != nil {
+		conn.Close()
+		return
+	}
+
+	switch t {
+	case msgAttach:
+		s.attachClient(conn, payload)
+	case msgInfo:
+		var info string
+		s.run(func() { info = s.info() })
+		writeMsg(conn, msgReply, []byte(info))
+		conn.Close()
+	case msgKill:
+		// shutdownNow exits the process, so run never returns
+		s.run(func() {
+			autosaveLayout()
+			writeMsg(conn, msgReply, nil)
+			conn.Close()
+			root.kill()
+			shutdownNow()
+		})
+	case msgCommand:
+		var err error
+		s.run(func() { err = handleCommand(string(payload)) })
+		reply(conn, nil, err)
+	case msgTree:
+		var tree []byte
+		var err error
+		s.run(func() { tree, err = json.MarshalIndent(getTree(), "", "  ") })
+		reply(conn, tree, err)
+	case msgBuffer:
+		var text string
+		var err error
+		s.run(func() {
+			var b *PasteBuffer
+			if b, err = getBufferFromString(string(payload)); err == nil {
+				text = b.text
+			}
+		})
+		reply(conn, []byte(text), err)
+	default:
+		log.Printf("Unexpected first message from client: %d", t)
+		conn.Close()
+	}
+}
+
+// run calls fn from the main loop in between keypresses, returning once it's done
+func (s *Server) run(fn func()) {
+	done := make(chan struct{})
+	s.commands <- func() {
+		fn()
+		close(done)
+	}
+	<-done
+}
+
+// post is like run, but returns right away. Output goroutines use it, since the main loop may be waiting on them
+func (s *Server) post(fn func()) {
+	go func() { s.commands <- fn }()
+}
+
+// reply answers a request then hangs up
+func reply(conn net.Conn, payload []byte, err error) {
+	if err != nil {
+		writeMsg(conn, msgError, []byte(err.Error()))
+	} else {
+		writeMsg(conn, msgReply, payload)
+	}
+	conn.Close()
+}
+
+// info describes the session for `3mux ls`
+func (s *Server) info() string {
+	s.mutex.Lock()
+	attached := len(s.clients)
+	s.mutex.Unlock()
+
+	return fmt.Sprintf("%s: %d panes (created %s) (%d attached)",
+		s.name, len(getPanesOfUniverse()), s.created.Format(time.ANSIC), attached)
+}
+
+// attachClient streams rendering to a new client and reads its input until it disconnects
+func (s *Server) attachClient(conn net.Conn, size []byte) {
+	inputReader, inputWriter := io.Pipe()
+	c := &client{
+		conn:  conn,
+		input: inputWriter,
+	}
+
+	s.mutex.Lock()
+	s.clients = append(s.clients, c)
+	s.mutex.Unlock()
+
+	// redraw everything for the new client
+	s.run(func() {
+		resize(decodeSize(size))
+		refreshStatusBar()
+	})
+
+	outputs := make(chan ecma48.Output, 64)
+	go func() {
+		parser := ecma48.NewParser(true)
+		parser.Parse(bufio.NewReader(inputReader), outputs)
+		close(outputs)
+	}()
+	go func() {
+		for output := range outputs {
+			s.inputs <- clientInput{client: c, Output: output}
+		}
+	}()
+
+	for {
+		t, payload, err := readMsg(conn)
+		if err != nil {
+			break
+		}
+
+		switch t {
+		case msgInput:
+			inputWriter.Write(payload)
+		case msgResize:
+			s.run(func() { resize(decodeSize(payload)) })
+		default:
+			log.Printf("Unexpected message from client: %d", t)
+		}
+	}
+
+	s.removeClient(c)
+}
+
+func (s *Server) removeClient(c *client) {
+	s.mutex.Lock()
+	for idx, other := range s.clients {
+		if other == c {
+			s.clients = append(s.clients[:idx], s.clients[idx+1:]...)
+			break
+		}
+	}
+	s.mutex.Unlock()
+
+	c.input.Close()
+	c.conn.Close()
+}
+
+// Write sends rendered output to every attached client. It is used as the renderer's output
+func (s *Server) Write(p []byte) (int, error) {
+	s.mutex.Lock()
+	clients := append([]*client{}, s.clients...)
+	s.mutex.Unlock()
+
+	for _, c := range clients {
+		if err := c.send(msgOutput, p); err != nil {
+			s.removeClient(c)
+		}
+	}
+
+	return len(p), nil
+}
+
+// detachClient disconnects a client while leaving its shells running
+func (s *Server) detachClient(c *client) {
+	if c == nil {
+		return
+	}
+	c.send(msgDetach, nil)
+	s.removeClient(c)
+}
+
+// close tells all clients the server is going away, passing along text for them to print
+func (s *Server) close(msg string) {
+	s.listener.Close()
+
+	s.mutex.Lock()
+	clients := append([]*client{}, s.clients...)
+	s.mutex.Unlock()
+
+	for _, c := range clients {
+		c.send(msgExit, []byte(msg))
+		s.removeClient(c)
+	}
+}
+
+func detach() {
+	server.detachClient(server.activeClient)
+}
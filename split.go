@@ -2,7 +2,10 @@ package main
 
 import (
 	"fmt"
+	"path/filepath"
+	"strings"
 
+	"github.com/aaronjanse/3mux/ecma48"
 	"github.com/aaronjanse/3mux/render"
 )
 
@@ -11,15 +14,45 @@ type Split struct {
 	elements          []Node
 	selectionIdx      int
 	verticallyStacked bool
+	mode              SplitMode
+
+	// paused is set while the split is hidden, e.g. on another workspace or behind a tab
+	paused bool
 
 	renderRect Rect
 }
 
+// A SplitMode is how a Split shows its children
+type SplitMode int
+
+// split modes
+const (
+	Tiled   SplitMode = iota // side by side, separated by lines
+	Tabbed                   // one at a time, below a row of tabs
+	Stacked                  // one at a time, below a title line for each child
+)
+
+func (m SplitMode) String() string {
+	switch m {
+	case Tabbed:
+		return "tabbed"
+	case Stacked:
+		return "stacked"
+	default:
+		return "split"
+	}
+}
+
 func (s *Split) serialize() string {
 	var out string
-	if s.verticallyStacked {
+	switch {
+	case s.mode == Tabbed:
+		out = "Tabbed"
+	case s.mode == Stacked:
+		out = "Stacked"
+	case s.verticallyStacked:
 		out = "VSplit"
-	} else {
+	default:
 		out = "HSplit"
 	}
 
@@ -58,12 +91,30 @@ func (s *Split) kill() {
 }
 
 func (s *Split) setPause(pause bool) {
-	for _, e := range s.elements {
-		e.contents.setPause(pause)
+	s.paused = pause
+	for idx, e := range s.elements {
+		// tabbed and stacked splits only show their selected child
+		hidden := s.mode != Tiled && idx != s.selectionIdx
+		e.contents.setPause(pause || hidden)
 	}
 }
 
 func (s *Split) selectAtCoords(x, y int) {
+	if s.mode != Tiled {
+		if y < s.renderRect.y+s.titleRows() {
+			for idx := range s.elements {
+				r := s.titleRect(idx)
+				if r.y == y && r.x <= x && x < r.x+r.w {
+					s.selectionIdx = idx
+					return
+				}
+			}
+		} else if child, ok := s.elements[s.selectionIdx].contents.(*Split); ok {
+			child.selectAtCoords(x, y)
+		}
+		return
+	}
+
 	for idx, n := range s.elements {
 		r := n.contents.getRenderRect()
 		vertValid := r.y <= y && y < r.y+r.h
@@ -91,6 +142,14 @@ func (s *Split) updateSelection(selected bool) {
 }
 
 func (s *Split) dragBorder(x1, y1, x2, y2 int) {
+	// there are no borders between tabs, only within the visible one
+	if s.mode != Tiled {
+		if child, ok := s.elements[s.selectionIdx].contents.(*Split); ok {
+			child.dragBorder(x1, y1, x2, y2)
+		}
+		return
+	}
+
 	for idx, n := range s.elements {
 		r := n.contents.getRenderRect()
 
@@ -168,7 +227,14 @@ func (s *Split) refreshRenderRect() {
 	w := s.renderRect.w
 	h := s.renderRect.h
 
-	s.redrawLines()
+	if s.mode != Tiled {
+		s.refreshTabs()
+		return
+	}
+
+	if !s.paused {
+		s.redrawLines()
+	}
 
 	var area int
 	if s.verticallyStacked {
@@ -201,6 +267,111 @@ func (s *Split) refreshRenderRect() {
 	}
 }
 
+// refreshTabs lays out a tabbed or stacked split, whose selected child gets all the space below the titles
+func (s *Split) refreshTabs() {
+	r := s.renderRect
+	rows := s.titleRows()
+
+	for idx, e := range s.elements {
+		if idx != s.selectionIdx {
+			e.contents.setPause(true)
+		} else if !s.paused {
+			e.contents.setPause(false)
+		}
+		e.contents.setRenderRect(r.x, r.y+rows, r.w, r.h-rows)
+	}
+
+	// drawn last since the selection border of the child overlaps the titles
+	if !s.paused {
+		s.drawTitles()
+	}
+}
+
+// titleRows is how many lines the titles of a tabbed or stacked split take up
+func (s *Split) titleRows() int {
+	rows := 1
+	if s.mode == Stacked {
+		rows = len(s.elements)
+	}
+
+	// always leave room for the selected child
+	if rows > s.renderRect.h-1 {
+		rows = s.renderRect.h - 1
+	}
+	return rows
+}
+
+// titleRect is where the title of a child of a tabbed or stacked split goes
+func (s *Split) titleRect(idx int) Rect {
+	r := s.renderRect
+	if s.mode == Stacked {
+		return Rect{x: r.x, y: r.y + idx, w: r.w, h: 1}
+	}
+
+	start := r.w * idx / len(s.elements)
+	end := r.w * (idx + 1) / len(s.elements)
+	return Rect{x: r.x + start, y: r.y, w: end - start, h: 1}
+}
+
+func (s *Split) drawTitles() {
+	for idx, e := range s.elements {
+		r := s.titleRect(idx)
+		if r.y >= s.renderRect.y+s.titleRows() {
+			break
+		}
+
+		// the selected child's title matches the selection border
+		style := render.Style{}
+		if idx == s.selectionIdx {
+			style.Reverse = true
+			style.Fg = ecma48.Color{
+				ColorMode: ecma48.ColorBit3Normal,
+				Code:      6,
+			}
+		}
+
+		title := []rune(" " + getTitle(e.contents))
+		for i := 0; i < r.w; i++ {
+			ch := ' '
+			if i < len(title) {
+				ch = title[i]
+			}
+			renderer.HandleCh(render.PositionedChar{
+				Rune:   ch,
+				Cursor: render.Cursor{X: r.x + i, Y: r.y, Style: style},
+			})
+		}
+	}
+}
+
+// getTitle describes a container for the titles of a tabbed or stacked split, e.g. `H[vim bash]` for a split
+func getTitle(c Container) string {
+	switch c := c.(type) {
+	case *Pane:
+		if c.vterm.Title != "" {
+			return c.vterm.Title
+		}
+		return filepath.Base(c.cmd.Args[0])
+	case *Split:
+		titles := []string{}
+		for _, e := range c.elements {
+			titles = append(titles, getTitle(e.contents))
+		}
+
+		prefix := "H"
+		switch {
+		case c.mode == Tabbed:
+			prefix = "T"
+		case c.mode == Stacked:
+			prefix = "S"
+		case c.verticallyStacked:
+			prefix = "V"
+		}
+		return prefix + "[" + strings.Join(titles, " ") + "]"
+	}
+	return ""
+}
+
 func (s *Split) redrawLines() {
 	x := s.renderRect.x
 	y := s.renderRect.y
@@ -0,0 +1,121 @@
+package main
+
+// The types below describe the layout as JSON for `3mux get-tree`
+
+type universeTree struct {
+	Session    string          `json:"session"`
+	Width      int             `json:"width"`
+	Height     int             `json:"height"`
+	Workspaces []workspaceTree `json:"workspaces"`
+	Scratchpad []paneTree      `json:"scratchpad"` // hidden scratchpad panes
+}
+
+type workspaceTree struct {
+	Num      int        `json:"num"`
+	Focused  bool       `json:"focused"`
+	Zoomed   bool       `json:"zoomed"`
+	Root     splitTree  `json:"root"`
+	Floating []paneTree `json:"floating"` // bottom to top
+}
+
+type splitTree struct {
+	Type        string   `json:"type"` // always "split"
+	Orientation string   `json:"orientation"`
+	Layout      string   `json:"layout"` // split, tabbed, or stacked
+	Size        float32  `json:"size"`   // fraction of the parent split
+	Rect        rectTree `json:"rect"`
+
+	// each child is either a splitTree or a paneTree
+	Children []interface{} `json:"children"`
+}
+
+type paneTree struct {
+	Type    string   `json:"type"` // always "pane"
+	ID      int      `json:"id"`
+	PID     int      `json:"pid"`
+	Cwd     string   `json:"cwd"`
+	Title   string   `json:"title"`
+	Size    float32  `json:"size"` // fraction of the parent split
+	Rect    rectTree `json:"rect"`
+	Focused bool     `json:"focused"`
+}
+
+type rectTree struct {
+	X int `json:"x"`
+	Y int `json:"y"`
+	W int `json:"w"`
+	H int `json:"h"`
+}
+
+func getTree() universeTree {
+	tree := universeTree{
+		Session:    server.name,
+		Width:      termW,
+		Height:     termH,
+		Workspaces: []workspaceTree{},
+		Scratchpad: []paneTree{},
+	}
+
+	for _, pane := range root.scratchpad {
+		tree.Scratchpad = append(tree.Scratchpad, getTreeOfPane(pane, 0))
+	}
+
+	for idx, ws := range root.workspaces {
+		floating := []paneTree{}
+		for _, pane := range ws.floating {
+			floating = append(floating, getTreeOfPane(pane, 0))
+		}
+
+		tree.Workspaces = append(tree.Workspaces, workspaceTree{
+			Num:      ws.num,
+			Focused:  idx == root.selectionIdx,
+			Zoomed:   ws.zoomed,
+			Root:     getTreeOfSplit(ws.contents, 1),
+			Floating: floating,
+		})
+	}
+
+	return tree
+}
+
+func getTreeOfSplit(s *Split, size float32) splitTree {
+	tree := splitTree{
+		Type:        "split",
+		Orientation: "horizontal",
+		Layout:      s.mode.String(),
+		Size:        size,
+		Rect:        getTreeOfRect(s.renderRect),
+		Children:    []interface{}{},
+	}
+	if s.verticallyStacked {
+		tree.Orientation = "vertical"
+	}
+
+	for _, e := range s.elements {
+		switch c := e.contents.(type) {
+		case *Split:
+			tree.Children = append(tree.Children, getTreeOfSplit(c, e.size))
+		case *Pane:
+			tree.Children = append(tree.Children, getTreeOfPane(c, e.size))
+		}
+	}
+
+	return tree
+}
+
+func getTreeOfPane(t *Pane, size float32) paneTree {
+	return paneTree{
+		Type:    "pane",
+		ID:      t.id,
+		PID:     t.cmd.Process.Pid,
+		Cwd:     t.cwd(),
+		Title:   t.vterm.Title,
+		Size:    size,
+		Rect:    getTreeOfRect(t.renderRect),
+		Focused: t.selected,
+	}
+}
+
+func getTreeOfRect(r Rect) rectTree {
+	return rectTree{X: r.x, Y: r.y, W: r.w, H: r.h}
+}
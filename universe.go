@@ -9,6 +9,9 @@ type Universe struct {
 	workspaces   []*Workspace
 	selectionIdx int
 
+	// scratchpad holds the hidden scratchpad panes, the next to be shown first
+	scratchpad []*Pane
+
 	renderRect Rect
 }
 
@@ -50,6 +53,12 @@ func (u *Universe) getRenderRect() Rect {
 func (u *Universe) kill() {
 	for _, n := range u.workspaces {
 		n.contents.kill()
+		for _, pane := range n.floating {
+			pane.kill()
+		}
+	}
+	for _, pane := range u.scratchpad {
+		pane.kill()
 	}
 }
 
@@ -67,18 +76,132 @@ func (u *Universe) refreshRenderRect() {
 	w := u.renderRect.w
 	h := u.renderRect.h
 
-	for _, child := range u.workspaces {
-		child.setRenderRect(x, y, w, h)
+	// hidden workspaces are reshaped when they are shown
+	u.workspaces[u.selectionIdx].setRenderRect(x, y, w, h)
+}
+
+// findWorkspace returns the index of the workspace numbered num, or -1 if it doesn't exist
+func (u *Universe) findWorkspace(num int) int {
+	for idx, ws := range u.workspaces {
+		if ws.num == num {
+			return idx
+		}
+	}
+	return -1
+}
+
+// addWorkspace inserts a hidden workspace, keeping workspaces ordered by number, and returns its index
+func (u *Universe) addWorkspace(ws *Workspace) int {
+	ws.setPause(true)
+
+	idx := 0
+	for idx < len(u.workspaces) && u.workspaces[idx].num < ws.num {
+		idx++
+	}
+	u.workspaces = append(u.workspaces[:idx], append([]*Workspace{ws}, u.workspaces[idx:]...)...)
+
+	if idx <= u.selectionIdx && len(u.workspaces) > 1 {
+		u.selectionIdx++
+	}
+
+	return idx
+}
+
+// removeWorkspace deletes a workspace. If it was visible, a neighbor is shown instead.
+// If it was the last one, a pane from the scratchpad is shown in its place so that pane isn't left running unseen
+func (u *Universe) removeWorkspace(idx int) {
+	num := u.workspaces[idx].num
+	u.workspaces = append(u.workspaces[:idx], u.workspaces[idx+1:]...)
+	if len(u.workspaces) == 0 {
+		if len(u.scratchpad) > 0 {
+			pane := u.scratchpad[0]
+			u.scratchpad = u.scratchpad[1:]
+			pane.scratch = false
+
+			u.workspaces = []*Workspace{newWorkspace(num, pane)}
+			u.selectionIdx = 0
+			u.workspaces[0].setPause(false)
+			u.updateSelection()
+			u.refreshRenderRect()
+		}
+		return
+	}
+
+	if idx < u.selectionIdx {
+		u.selectionIdx--
+	} else if idx == u.selectionIdx {
+		if idx == len(u.workspaces) {
+			idx--
+		}
+		u.selectionIdx = idx
+		u.workspaces[idx].setPause(false)
+		u.updateSelection()
+		u.refreshRenderRect()
+	}
+}
+
+// selectWorkspace hides the visible workspace and shows the one at idx
+func (u *Universe) selectWorkspace(idx int) {
+	if idx != u.selectionIdx {
+		u.workspaces[u.selectionIdx].setPause(true)
+	}
+	u.selectionIdx = idx
+	u.workspaces[idx].setPause(false)
+
+	u.updateSelection()
+	u.refreshRenderRect()
+}
+
+// removeTheDead removes panes whose shells have exited, along with any workspaces left empty
+func (u *Universe) removeTheDead() {
+	for idx := len(u.scratchpad) - 1; idx >= 0; idx-- {
+		if u.scratchpad[idx].Dead {
+			u.scratchpad[idx].kill()
+			u.scratchpad = append(u.scratchpad[:idx], u.scratchpad[idx+1:]...)
+		}
+	}
+
+	for idx := len(u.workspaces) - 1; idx >= 0; idx-- {
+		ws := u.workspaces[idx]
+
+		if ws.zoomed && ws.selectedPane().Dead {
+			ws.setZoom(false)
+		}
+
+		removeTheDead(Path{idx})
+		for j := len(ws.floating) - 1; j >= 0; j-- {
+			if ws.floating[j].Dead {
+				ws.removeFloating(j).kill()
+			}
+		}
+
+		if !ws.refill() {
+			u.removeWorkspace(idx)
+		}
 	}
 }
 
 func (u *Universe) AddPane() {
-	u.workspaces[u.selectionIdx].addPane()
+	u.AddPaneWithCommand("", nil, nil)
+}
+
+// AddPaneWithCommand is like AddPane, but the new pane runs args in dir (see newTermWithCommand).
+// An empty dir means the focused pane's working directory.
+func (u *Universe) AddPaneWithCommand(dir string, args []string, env []string) {
+	if dir == "" {
+		dir = newPaneDir()
+	}
+	u.workspaces[u.selectionIdx].focusDepth = 0
+	u.workspaces[u.selectionIdx].floatingFocused = false
+	u.workspaces[u.selectionIdx].setZoom(false)
+	u.workspaces[u.selectionIdx].addPane(dir, args, env)
 	u.updateSelection()
 	u.refreshRenderRect() // FIXME only needs to redraw lines!
 }
 
 func (u *Universe) SelectAtCoords(x, y int) {
+	u.workspaces[u.selectionIdx].focusDepth = 0
+	u.workspaces[u.selectionIdx].floatingFocused = false
 	u.workspaces[u.selectionIdx].selectAtCoords(x, y)
 	u.updateSelection()
 	u.refreshRenderRect() // FIXME only needs to redraw lines!
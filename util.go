@@ -5,19 +5,25 @@ import (
 	"github.com/aaronjanse/3mux/render"
 )
 
+// selectionStyle is the highlight color of the selected pane's border
+var selectionStyle = render.Style{
+	Fg: ecma48.Color{
+		ColorMode: ecma48.ColorBit3Normal,
+		Code:      6,
+	},
+}
+
 func drawSelectionBorder(r Rect) {
+	drawBorder(r, selectionStyle, 0)
+}
+
+// drawBorder outlines r on the given layer of the renderer, leaving out sides at the edges of the screen
+func drawBorder(r Rect, style render.Style, layer int) {
 	leftBorder := r.x > 0
 	rightBorder := r.x+r.w+1 < termW
 	topBorder := r.y > 0
 	bottomBorder := r.y+r.h+1 < termH
 
-	style := render.Style{
-		Fg: ecma48.Color{
-			ColorMode: ecma48.ColorBit3Normal,
-			Code:      6,
-		},
-	}
-
 	// draw lines
 	if leftBorder {
 		for i := 0; i < r.h; i++ {
@@ -30,7 +36,7 @@ func drawSelectionBorder(r Rect) {
 				},
 			}
 
-			renderer.HandleCh(ch)
+			renderer.HandleLayeredCh(ch, layer)
 		}
 	}
 	if rightBorder {
@@ -44,7 +50,7 @@ func drawSelectionBorder(r Rect) {
 				},
 			}
 
-			renderer.HandleCh(ch)
+			renderer.HandleLayeredCh(ch, layer)
 		}
 	}
 	if topBorder {
@@ -58,7 +64,7 @@ func drawSelectionBorder(r Rect) {
 				},
 			}
 
-			renderer.HandleCh(ch)
+			renderer.HandleLayeredCh(ch, layer)
 		}
 	}
 	if bottomBorder {
@@ -72,7 +78,7 @@ func drawSelectionBorder(r Rect) {
 				},
 			}
 
-			renderer.HandleCh(ch)
+			renderer.HandleLayeredCh(ch, layer)
 		}
 	}
 
@@ -87,7 +93,7 @@ func drawSelectionBorder(r Rect) {
 			},
 		}
 
-		renderer.HandleCh(ch)
+		renderer.HandleLayeredCh(ch, layer)
 	}
 	if topBorder && rightBorder {
 		ch := render.PositionedChar{
@@ -99,7 +105,7 @@ func drawSelectionBorder(r Rect) {
 			},
 		}
 
-		renderer.HandleCh(ch)
+		renderer.HandleLayeredCh(ch, layer)
 	}
 	if bottomBorder && leftBorder {
 		ch := render.PositionedChar{
@@ -111,7 +117,7 @@ func drawSelectionBorder(r Rect) {
 			},
 		}
 
-		renderer.HandleCh(ch)
+		renderer.HandleLayeredCh(ch, layer)
 	}
 	if bottomBorder && rightBorder {
 		ch := render.PositionedChar{
@@ -123,6 +129,6 @@ func drawSelectionBorder(r Rect) {
 			},
 		}
 
-		renderer.HandleCh(ch)
+		renderer.HandleLayeredCh(ch, layer)
 	}
 }
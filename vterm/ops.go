@@ -1,6 +1,10 @@
 package vterm
 
 import (
+	"encoding/base64"
+	"log"
+	"strings"
+
 	"github.com/aaronjanse/3mux/render"
 )
 
@@ -16,8 +20,11 @@ func (v *VTerm) ScrollbackUp() {
 		return
 	}
 
-	if v.ScrollbackPos-5 >= 0 {
+	if v.ScrollbackPos > 0 {
 		v.ScrollbackPos -= 5
+		if v.ScrollbackPos < 0 {
+			v.ScrollbackPos = 0
+		}
 		v.RedrawWindow()
 	}
 }
@@ -28,12 +35,11 @@ func (v *VTerm) ScrollbackDown() {
 		return
 	}
 
-	if len(v.Scrollback) == 0 {
-		return
-	}
-
-	if v.ScrollbackPos+5 < len(v.Scrollback) {
+	if v.ScrollbackPos < len(v.Scrollback) {
 		v.ScrollbackPos += 5
+		if v.ScrollbackPos > len(v.Scrollback) {
+			v.ScrollbackPos = len(v.Scrollback)
+		}
 		v.RedrawWindow()
 	}
 }
@@ -46,7 +52,7 @@ func (v *VTerm) RefreshCursor() {
 }
 
 func (v *VTerm) forceRefreshCursor() {
-	if v.IsPaused {
+	if v.IsPaused || v.isFrozen {
 		return
 	}
 	v.parentSetCursor(v.Cursor.X, v.Cursor.Y)
@@ -183,8 +189,8 @@ func (v *VTerm) putChar(ch rune, wide bool) {
 	positionedChar.Cursor.Y += v.y
 
 	// TODO: print to the window based on scrolling position
-	if !v.usingSlowRefresh {
-		v.renderer.HandleCh(positionedChar)
+	if !v.usingSlowRefresh && !v.IsPaused {
+		v.renderer.HandleLayeredCh(positionedChar, v.Layer)
 	}
 
 	if v.Cursor.X < v.w {
@@ -203,6 +209,10 @@ func (v *VTerm) RedrawWindow() {
 }
 
 func (v *VTerm) forceRedrawWindow() {
+	if v.IsPaused {
+		return
+	}
+
 	if v.ScrollbackPos < v.h {
 		for y := 0; y < v.h-v.ScrollbackPos; y++ {
 			for x := 0; x < v.w; x++ {
@@ -219,7 +229,7 @@ func (v *VTerm) forceRedrawWindow() {
 					},
 				}
 
-				v.renderer.HandleCh(ch)
+				v.renderer.HandleLayeredCh(ch, v.Layer)
 			}
 		}
 	}
@@ -235,7 +245,10 @@ func (v *VTerm) forceRedrawWindow() {
 		}
 		for y := 0; y < numLinesVisible; y++ {
 			for x := 0; x < v.w; x++ {
-				idx := len(v.Scrollback) - v.ScrollbackPos + y - 1
+				idx := len(v.Scrollback) - v.ScrollbackPos + y
+				if idx < 0 {
+					continue
+				}
 
 				if x < len(v.Scrollback[idx]) {
 					ch := render.PositionedChar{
@@ -246,7 +259,7 @@ func (v *VTerm) forceRedrawWindow() {
 							X: v.x + x, Y: v.y + y, Style: v.Scrollback[idx][x].Style,
 						},
 					}
-					v.renderer.HandleCh(ch)
+					v.renderer.HandleLayeredCh(ch, v.Layer)
 				} else {
 					ch := render.PositionedChar{
 						Rune: ' ',
@@ -254,9 +267,33 @@ func (v *VTerm) forceRedrawWindow() {
 							X: v.x + x, Y: v.y + y, Style: render.Style{},
 						},
 					}
-					v.renderer.HandleCh(ch)
+					v.renderer.HandleLayeredCh(ch, v.Layer)
 				}
 			}
 		}
 	}
 }
+
+// setClipboard handles the payload of an OSC 52 sequence: the selections to set, then the text in base64.
+// Queries for the clipboard's contents (`?`) are ignored so programs can't read what was copied elsewhere
+func (v *VTerm) setClipboard(data string) {
+	sep := strings.Index(data, ";")
+	if sep == -1 {
+		log.Printf("Invalid OSC 52: %q", data)
+		return
+	}
+
+	encoded := data[sep+1:]
+	if encoded == "?" {
+		return
+	}
+
+	text, err := base64.StdEncoding.DecodeString(encoded)
+	if err != nil {
+		log.Printf("Invalid OSC 52: %s", err.Error())
+		return
+	}
+	if len(text) > 0 {
+		v.parentSetClipboard(string(text))
+	}
+}
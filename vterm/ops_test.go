@@ -0,0 +1,35 @@
+package vterm
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestSetClipboard(t *testing.T) {
+	tests := []struct {
+		data string
+		want []string // what the pane is given, if anything
+	}{
+		{"c;aGVsbG8=", []string{"hello"}},
+		{";aGk=", []string{"hi"}},
+		{"pc;bGluZSAxCmxpbmUgMg==", []string{"line 1\nline 2"}},
+		{"c;w6l0w6k=", []string{"été"}},
+
+		{"c;?", nil},      // a query for the clipboard's contents
+		{"c;", nil},       // nothing to copy
+		{"c;!!!!", nil},   // not base64
+		{"aGVsbG8=", nil}, // no selection
+	}
+
+	for _, test := range tests {
+		var got []string
+		v := &VTerm{parentSetClipboard: func(text string) {
+			got = append(got, text)
+		}}
+
+		v.setClipboard(test.data)
+		if !reflect.DeepEqual(got, test.want) {
+			t.Errorf("setClipboard(%q) copied %q, want %q", test.data, got, test.want)
+		}
+	}
+}
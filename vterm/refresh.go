@@ -23,11 +23,15 @@ func (v *VTerm) useSlowRefresh() {
 		ticker := time.NewTicker(time.Millisecond * 250)
 
 		for range ticker.C {
-			if !v.usingSlowRefresh || v.IsPaused {
+			if !v.usingSlowRefresh {
 				ticker.Stop()
 				return
 			}
 
+			if v.IsPaused || v.isFrozen {
+				continue
+			}
+
 			v.forceRedrawWindow()
 			v.forceRefreshCursor()
 		}
@@ -3,6 +3,7 @@ package vterm
 import (
 	"bufio"
 	"log"
+	"net/url"
 	"sync/atomic"
 
 	"github.com/aaronjanse/3mux/ecma48"
@@ -28,9 +29,14 @@ const (
 	StateOscString
 )
 
+// ProcessStream is a blocking function that handles output until input ends.
+// It can be called again afterwards, e.g. to print a message once the shell exits.
 func (v *VTerm) ProcessStream(input *bufio.Reader) {
 	stdout := make(chan ecma48.Output, 3200000)
 
+	// the lag calculation below compares against a fresh parser
+	v.runeCounter = 0
+
 	parser := ecma48.NewParser(false)
 
 	go func() {
@@ -40,13 +46,13 @@ func (v *VTerm) ProcessStream(input *bufio.Reader) {
 
 	for {
 		select {
-		case p := <-v.ChangePause:
+		case f := <-v.ChangeFreeze:
 			for {
-				v.IsPaused = p
-				if !p {
+				v.isFrozen = f
+				if !f {
 					break
 				}
-				p = <-v.ChangePause
+				f = <-v.ChangeFreeze
 			}
 		case output := <-stdout:
 			v.runeCounter += uint64(len(output.Raw))
@@ -116,6 +122,8 @@ func (v *VTerm) ProcessStream(input *bufio.Reader) {
 						}
 					}
 					v.UsingAltScreen = x.On
+				case 2004:
+					v.BracketedPaste = x.On
 				default:
 					log.Printf("Unrecognized DEC Private Mode: %d", x.Code)
 				}
@@ -228,6 +236,21 @@ func (v *VTerm) ProcessStream(input *bufio.Reader) {
 			case ecma48.StyleUnderline:
 				v.Cursor.Style.Underline = bool(x)
 
+			case ecma48.OSC:
+				switch x.Code {
+				case 0, 2: // icon name & window title, window title
+					v.Title = x.Data
+				case 7: // working directory, e.g. file://host/path
+					u, err := url.Parse(x.Data)
+					if err == nil && u.Scheme == "file" && isLocalHost(u.Host) {
+						v.Cwd = u.Path
+					}
+				case 52: // clipboard, e.g. c;aGVsbG8=
+					v.setClipboard(x.Data)
+				default:
+					log.Printf("Unrecognized OSC: %d", x.Code)
+				}
+
 			case ecma48.Unrecognized:
 				log.Printf("?? %q", output.Raw)
 			default:
@@ -1,10 +1,20 @@
 package vterm
 
 import (
+	"os"
 	"strconv"
 	"strings"
 )
 
+// isLocalHost tells whether a hostname from OSC 7 refers to this machine rather than e.g. an SSH server
+func isLocalHost(host string) bool {
+	if host == "" || host == "localhost" {
+		return true
+	}
+	hostname, err := os.Hostname()
+	return err == nil && host == hostname
+}
+
 // parseSemicolonNumSeq parses a series of numbers separated by semicolons, replacing empty values with the given default value
 // FIXME: this function is an unclean way to parse parameters, espcially when it comes to default values
 func parseSemicolonNumSeq(s string, d int) []int {
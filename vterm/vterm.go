@@ -31,6 +31,9 @@ type VTerm struct {
 	ScrollbackPos int             // ScrollbackPos is the number of lines of scrollback visible
 
 	UsingAltScreen bool
+
+	// BracketedPaste is set by programs with mode 2004 to have pasted text wrapped in ESC[200~ and ESC[201~
+	BracketedPaste bool
 	screenBackup   [][]render.Char
 
 	NeedsRedraw bool
@@ -41,6 +44,12 @@ type VTerm struct {
 
 	Cursor render.Cursor
 
+	// Title is set by the program running in the terminal
+	Title string
+
+	// Cwd is the working directory reported by the shell with OSC 7, if it does so
+	Cwd string
+
 	renderer *render.Renderer
 
 	// TODO: delete `blankLine`
@@ -49,6 +58,9 @@ type VTerm struct {
 	// parentSetCursor sets physical host's cursor taking the pane location into account
 	parentSetCursor func(x, y int)
 
+	// parentSetClipboard is given text the program copied with OSC 52, to be checked against the pane's clipboard policy
+	parentSetClipboard func(text string)
+
 	in  <-chan rune
 	out chan<- rune
 
@@ -56,15 +68,22 @@ type VTerm struct {
 
 	scrollingRegion ScrollingRegion
 
-	ChangePause   chan bool
+	// ChangeFreeze stops processing output until false is sent, giving the sender full control of Screen
+	ChangeFreeze chan bool
+	isFrozen     bool
+
+	// IsPaused is true when nothing should be drawn to the renderer
 	IsPaused      bool
 	DebugSlowMode bool
 
+	// Layer is where the VTerm is drawn in the renderer's stack of layers. Floating windows are above 0
+	Layer int
+
 	parser *Parser
 }
 
 // NewVTerm returns a VTerm ready to be used by its exported methods
-func NewVTerm(renderer *render.Renderer, parentSetCursor func(x, y int)) *VTerm {
+func NewVTerm(renderer *render.Renderer, parentSetCursor func(x, y int), parentSetClipboard func(text string)) *VTerm {
 	w := 10
 	h := 10
 
@@ -82,21 +101,22 @@ func NewVTerm(renderer *render.Renderer, parentSetCursor func(x, y int)) *VTerm
 
 	v := &VTerm{
 		x: 0, y: 0,
-		w:                w,
-		h:                h,
-		blankLine:        []render.Char{},
-		Screen:           screen,
-		Scrollback:       [][]render.Char{},
-		UsingAltScreen:   false,
-		Cursor:           render.Cursor{},
-		usingSlowRefresh: false,
-		renderer:         renderer,
-		parentSetCursor:  parentSetCursor,
-		scrollingRegion:  ScrollingRegion{top: 0, bottom: h - 1},
-		NeedsRedraw:      false,
-		ChangePause:      make(chan bool, 1),
-		IsPaused:         false,
-		DebugSlowMode:    false,
+		w:                  w,
+		h:                  h,
+		blankLine:          []render.Char{},
+		Screen:             screen,
+		Scrollback:         [][]render.Char{},
+		UsingAltScreen:     false,
+		Cursor:             render.Cursor{},
+		usingSlowRefresh:   false,
+		renderer:           renderer,
+		parentSetCursor:    parentSetCursor,
+		parentSetClipboard: parentSetClipboard,
+		scrollingRegion:    ScrollingRegion{top: 0, bottom: h - 1},
+		NeedsRedraw:        false,
+		ChangeFreeze:       make(chan bool, 1),
+		IsPaused:           false,
+		DebugSlowMode:      false,
 		parser: &Parser{
 			state:        StateGround,
 			private:      nil,
@@ -114,6 +134,11 @@ func (v *VTerm) Kill() {
 	v.usingSlowRefresh = false
 }
 
+// SetPaused stops or resumes drawing to the renderer. Output is still processed while paused
+func (v *VTerm) SetPaused(paused bool) {
+	v.IsPaused = paused
+}
+
 // Reshape safely updates a VTerm's width & height
 func (v *VTerm) Reshape(x, y, w, h int) {
 
@@ -1,30 +1,61 @@
 package main
 
+import (
+	"fmt"
+)
+
 func search() {
-	getSelection().getContainer().(*Pane).toggleSearch()
+	root.workspaces[root.selectionIdx].focusedPane().toggleSearch()
 }
 
-func fullscreen() {
-	root.workspaces[root.selectionIdx].doFullscreen = true
-	path := getSelection()
+func enterCopyMode() {
+	root.workspaces[root.selectionIdx].focusedPane().enterCopyMode()
+}
 
-	root.workspaces[root.selectionIdx].contents.setPause(true)
+// zoom shows the selected pane over the whole workspace. The layout is kept underneath for when it's unzoomed
+func zoom() {
+	ws := root.workspaces[root.selectionIdx]
+
+	// only tiled panes can be zoomed
+	if ws.floatingFocused {
+		return
+	}
 
-	r := root.workspaces[root.selectionIdx].contents.getRenderRect()
-	specaialPane := path.getContainer()
-	specaialPane.setRenderRect(r.x, r.y, r.w, r.h)
-	specaialPane.setPause(false)
+	ws.focusDepth = 0
+	ws.setZoom(true)
+	root.refreshRenderRect()
 }
 
-func unfullscreen() {
-	root.workspaces[root.selectionIdx].doFullscreen = false
-	root.workspaces[root.selectionIdx].contents.setPause(false)
+// unzoom shows the rest of the current workspace again. Operations that change the layout or move focus
+// call it first, so they never act on panes hidden behind a zoomed one
+func unzoom() {
+	ws := root.workspaces[root.selectionIdx]
+	if !ws.zoomed {
+		return
+	}
 
+	ws.setZoom(false)
 	root.refreshRenderRect()
 }
 
+func toggleZoom() {
+	if root.workspaces[root.selectionIdx].zoomed {
+		unzoom()
+	} else {
+		zoom()
+	}
+}
+
 func moveWindow(d Direction) {
-	path := getSelection()
+	unzoom()
+
+	if root.workspaces[root.selectionIdx].floatingFocused {
+		dx, dy := getFloatingShift(d)
+		moveFloating(dx, dy)
+		return
+	}
+
+	path := getFocus()
 	parent, parentPath := path.getParent()
 
 	vert := parent.verticallyStacked
@@ -177,23 +208,229 @@ func moveWindow(d Direction) {
 }
 
 func killWindow() {
-	parent, parentPath := getSelection().getParent()
-	t := parentPath.popContainer(parent.selectionIdx)
-	t.(*Pane).kill()
+	ws := root.workspaces[root.selectionIdx]
+	if ws.floatingFocused {
+		ws.removeFloating(len(ws.floating) - 1).kill()
+		root.updateSelection()
+		return
+	}
 
-	// FIXME: allows for only one workspace
-	if len(root.workspaces[root.selectionIdx].contents.elements) == 0 {
-		shutdownNow()
+	killPane(getFocus())
+}
+
+// killPane closes the pane at path, or every pane of the split at path, removing its workspace if nothing is left there
+func killPane(path Path) {
+	ws := root.workspaces[path[0]]
+	c := path.getContainer()
+	if ws.zoomed {
+		// the zoomed pane may be inside a killed split
+		zoomed := ws.selectedPane()
+		if split, ok := c.(*Split); (ok && containsPane(split, zoomed)) || c == zoomed {
+			ws.setZoom(false)
+		}
+	}
+
+	_, parentPath := path.getParent()
+	parentPath.popContainer(path[len(path)-1])
+	c.kill()
+	ws.focusDepth = 0
+
+	if !ws.refill() {
+		root.removeWorkspace(path[0])
+		if len(root.workspaces) == 0 {
+			shutdownNow()
+			return
+		}
+	}
+
+	root.simplify()
+	root.updateSelection()
+}
+
+// killPaneAt closes a pane wherever it is
+func killPaneAt(loc *PaneLocation) {
+	switch {
+	case loc.scratchpadIdx != -1:
+		root.scratchpad = append(root.scratchpad[:loc.scratchpadIdx], root.scratchpad[loc.scratchpadIdx+1:]...)
+		loc.pane.kill()
+	case loc.floatingIdx != -1:
+		root.workspaces[loc.path[0]].removeFloating(loc.floatingIdx).kill()
+		root.updateSelection()
+	default:
+		killPane(loc.path)
+	}
+}
+
+// focusPane focuses a pane, switching to its workspace if needed.
+// A floating pane is raised, and a pane in the scratchpad is shown on the current workspace
+func focusPane(loc *PaneLocation) {
+	switch {
+	case loc.scratchpadIdx != -1:
+		unzoom()
+		showFromScratchpad(loc.scratchpadIdx)
+		return
+	case loc.floatingIdx != -1:
+		// floating panes are hidden while zoomed
+		root.workspaces[loc.path[0]].setZoom(false)
+		root.selectWorkspace(loc.path[0])
+		raiseFloating(loc.floatingIdx)
 		return
 	}
 
-	// select the new Term
-	newTerm := getSelection().getContainer().(*Pane)
-	newTerm.selected = true
-	newTerm.softRefresh()
-	newTerm.vterm.RefreshCursor()
+	path := loc.path
+	ws := root.workspaces[path[0]]
+	if ws.zoomed && ws.selectedPane() != path.getContainer() {
+		ws.setZoom(false)
+	}
+	ws.floatingFocused = false
+
+	split := ws.contents
+	for _, idx := range path[1:] {
+		split.selectionIdx = idx
+		if child, ok := split.elements[idx].contents.(*Split); ok {
+			split = child
+		}
+	}
+
+	ws.focusDepth = 0
+
+	// also redraws the workspace if we were already on it
+	root.selectWorkspace(path[0])
+}
+
+// splitPane adds a new pane next to the selected one, either below it or to its right
+func splitPane(verticallyStacked bool) {
+	splitNext(verticallyStacked)
+
+	root.AddPane()
+	root.simplify()
+	root.refreshRenderRect()
+}
+
+// splitNext makes the next new pane open below the selected one or to its right, like i3's `split v` and `split h`
+func splitNext(verticallyStacked bool) {
+	unzoom()
+
+	path := getSelection()
+	parent, _ := path.getParent()
+
+	// new panes are already added to the parent, so it can just be turned
+	if len(parent.elements) == 1 && parent.mode == Tiled {
+		parent.verticallyStacked = verticallyStacked
+		return
+	}
+
+	pane := path.getContainer().(*Pane)
+	parent.elements[parent.selectionIdx].contents = &Split{
+		verticallyStacked: verticallyStacked,
+		selectionIdx:      0,
+		elements: []Node{Node{
+			size:     1,
+			contents: pane,
+		}},
+		renderRect: pane.renderRect,
+	}
+}
+
+// focusParent moves focus from the selected pane (or focused split) to the split that holds it,
+// so that moving, resizing, and killing act on the whole split
+func focusParent() {
+	unzoom()
+
+	ws := root.workspaces[root.selectionIdx]
+	if len(getFocus()) > 2 {
+		ws.focusDepth = len(getSelection()) - len(getFocus()) + 1
+	}
+	root.refreshRenderRect()
+}
+
+// focusChild undoes focusParent
+func focusChild() {
+	unzoom()
+
+	ws := root.workspaces[root.selectionIdx]
+	ws.focusDepth = len(getSelection()) - len(getFocus())
+	if ws.focusDepth > 0 {
+		ws.focusDepth--
+	}
+	root.refreshRenderRect()
+}
+
+// setSplitMode changes how the selected pane and its siblings are shown.
+// Tabs are switched between like a horizontal split, and stacked titles like a vertical one.
+func setSplitMode(mode SplitMode) {
+	unzoom()
+
+	parent, _ := getSelection().getParent()
+	parent.mode = mode
+	switch mode {
+	case Tabbed:
+		parent.verticallyStacked = false
+	case Stacked:
+		parent.verticallyStacked = true
+	}
+
+	// show or hide the other children
+	parent.setPause(parent.paused)
 
 	root.simplify()
+	root.refreshRenderRect()
+}
+
+// switchWorkspace shows workspace number n, creating it if it doesn't exist yet
+func switchWorkspace(n int) {
+	if root.workspaces[root.selectionIdx].num == n {
+		return
+	}
+
+	idx := root.findWorkspace(n)
+	if idx == -1 {
+		idx = root.addWorkspace(newWorkspace(n, newTermWithCommand(true, newPaneDir(), nil, nil)))
+	}
+
+	root.selectWorkspace(idx)
+}
+
+// movePaneToWorkspace sends the selected pane to workspace number n, creating it if it doesn't exist yet.
+// If no panes are left behind, the now-empty workspace is removed and we follow the pane.
+func movePaneToWorkspace(n int) {
+	ws := root.workspaces[root.selectionIdx]
+	if ws.num == n {
+		return
+	}
+
+	unzoom()
+
+	floating := ws.floatingFocused
+	var pane *Pane
+	if floating {
+		pane = ws.removeFloating(len(ws.floating) - 1)
+	} else {
+		parent, parentPath := getSelection().getParent()
+		pane = parentPath.popContainer(parent.selectionIdx).(*Pane)
+	}
+	pane.setPause(true)
+
+	if idx := root.findWorkspace(n); idx == -1 {
+		root.addWorkspace(newWorkspace(n, pane))
+	} else if floating {
+		// it stays floating, above the others there
+		dest := root.workspaces[idx]
+		dest.floating = append(dest.floating, pane)
+	} else {
+		dest := root.workspaces[idx].contents
+		dest.insertContainer(pane, len(dest.elements))
+		dest.selectionIdx = len(dest.elements) - 1
+	}
+
+	if !ws.refill() {
+		root.removeWorkspace(root.selectionIdx)
+		root.selectWorkspace(root.findWorkspace(n))
+	}
+
+	root.simplify()
+	root.updateSelection()
+	root.refreshRenderRect()
 }
 
 // stuff like h(h(x), y) -> h(x, y)
@@ -202,6 +439,7 @@ func (s *Split) simplify() {
 		switch child := (*s).elements[0].contents.(type) {
 		case *Split:
 			s.verticallyStacked = child.verticallyStacked
+			s.mode = child.mode
 			s.elements = child.elements
 			s.selectionIdx = child.selectionIdx
 		}
@@ -211,7 +449,9 @@ func (s *Split) simplify() {
 		for idx, n := range (*s).elements {
 			switch child := n.contents.(type) {
 			case *Split:
-				if child.verticallyStacked == s.verticallyStacked {
+				// tabs are kept separate from their surroundings
+				bothTiled := s.mode == Tiled && child.mode == Tiled
+				if bothTiled && child.verticallyStacked == s.verticallyStacked {
 					for j := range child.elements {
 						child.elements[j].size *= n.size
 					}
@@ -241,12 +481,21 @@ func (s *Split) simplify() {
 }
 
 func moveSelection(d Direction) {
+	unzoom()
+
+	// go back to the tiled panes first
+	if root.workspaces[root.selectionIdx].floatingFocused {
+		focusFloating()
+		return
+	}
+
 	path := getSelection()
 
 	// deselect the old Term
 	oldTerm := path.getContainer().(*Pane)
 	oldTerm.selected = false
 	oldTerm.softRefresh()
+	root.workspaces[root.selectionIdx].focusDepth = 0
 
 	parent, _ := path.getParent()
 
@@ -295,10 +544,10 @@ func moveSelection(d Direction) {
 	root.refreshRenderRect()
 }
 
-func (s *Split) addPane() {
+func (s *Split) addPane(dir string, args []string, env []string) {
 	switch x := s.elements[s.selectionIdx].contents.(type) {
 	case *Split:
-		x.addPane()
+		x.addPane(dir, args, env)
 		return
 	}
 
@@ -315,7 +564,7 @@ func (s *Split) addPane() {
 	}
 
 	// add new child
-	createdTerm := newTerm(true)
+	createdTerm := newTermWithCommand(true, dir, args, env)
 	s.elements = append(s.elements, Node{
 		size:     size,
 		contents: createdTerm,
@@ -329,7 +578,15 @@ func (s *Split) addPane() {
 }
 
 func resizeWindow(d Direction, diff float32) {
-	resizeWindowImpl(getSelection(), d, diff)
+	unzoom()
+
+	if root.workspaces[root.selectionIdx].floatingFocused {
+		dw, dh := getFloatingShift(d)
+		resizeFloating(dw, dh)
+		return
+	}
+
+	resizeWindowImpl(getFocus(), d, diff)
 }
 
 func resizeWindowImpl(path Path, d Direction, diff float32) {
@@ -338,6 +595,14 @@ func resizeWindowImpl(path Path, d Direction, diff float32) {
 	const shift = 0.1
 	var delta float32
 
+	// only the whole tabbed or stacked split can be resized
+	if parent.mode != Tiled {
+		if len(parentPath) > 1 {
+			resizeWindowImpl(parentPath, d, diff)
+		}
+		return
+	}
+
 	if parent.verticallyStacked {
 		if d == Left || d == Right {
 			resizeWindowImpl(parentPath, d, diff)
@@ -407,3 +672,133 @@ func resizeWindowImpl(path Path, d Direction, diff float32) {
 
 	root.refreshRenderRect()
 }
+
+// swapPanes exchanges the panes at two paths, which may be on different workspaces.
+// The selected pane stays selected unless it is sent to another workspace.
+func swapPanes(a, b Path) {
+	unzoom()
+
+	selected := getSelection().getContainer().(*Pane)
+
+	parentA, _ := a.getParent()
+	parentB, _ := b.getParent()
+	nodeA := &parentA.elements[a[len(a)-1]]
+	nodeB := &parentB.elements[b[len(b)-1]]
+	nodeA.contents, nodeB.contents = nodeB.contents, nodeA.contents
+
+	if path := findTiledPane(selected.id); path[0] == root.selectionIdx {
+		setSelection(path)
+	}
+	root.workspaces[root.selectionIdx].focusDepth = 0
+
+	// a pane may have moved onto or off of the screen, or out from behind a tab
+	for _, idx := range []int{a[0], b[0]} {
+		root.workspaces[idx].setPause(idx != root.selectionIdx)
+	}
+
+	root.updateSelection()
+	root.refreshRenderRect()
+}
+
+// swapWithPane swaps the focused pane with another one, which may be floating or in the scratchpad.
+// Each takes the other's place, and the focused pane stays focused unless it leaves this workspace.
+func swapWithPane(loc *PaneLocation) {
+	unzoom()
+
+	ws := root.workspaces[root.selectionIdx]
+	if !ws.floatingFocused && loc.floatingIdx == -1 && loc.scratchpadIdx == -1 {
+		swapPanes(getSelection(), loc.path)
+		return
+	}
+
+	selected := findPane(ws.focusedPane().id)
+	if selected.pane == loc.pane {
+		return
+	}
+	selected.put(loc.pane)
+	loc.put(selected.pane)
+	ws.focusDepth = 0
+
+	for _, l := range []*PaneLocation{selected, loc} {
+		if l.scratchpadIdx == -1 {
+			root.workspaces[l.path[0]].setPause(l.path[0] != root.selectionIdx)
+		}
+	}
+
+	if moved := findPane(selected.pane.id); moved.scratchpadIdx == -1 && moved.path[0] == root.selectionIdx {
+		focusPane(moved)
+	}
+
+	root.updateSelection()
+	root.refreshRenderRect()
+}
+
+// put puts a pane where the pane at l is, leaving that pane nowhere
+func (l *PaneLocation) put(pane *Pane) {
+	switch {
+	case l.scratchpadIdx != -1:
+		pane.renderRect.w = l.pane.renderRect.w
+		pane.renderRect.h = l.pane.renderRect.h
+		pane.vterm.Layer = 0
+		pane.scratch = true
+		pane.setPause(true)
+		pane.UpdateSelection(false)
+		root.scratchpad[l.scratchpadIdx] = pane
+	case l.floatingIdx != -1:
+		pane.renderRect = l.pane.renderRect
+		pane.scratch = false
+		root.workspaces[l.path[0]].floating[l.floatingIdx] = pane
+	default:
+		pane.vterm.Layer = 0
+		pane.scratch = false
+		parent, _ := l.path.getParent()
+		parent.elements[l.path[len(l.path)-1]].contents = pane
+	}
+}
+
+// markedPaneID is the pane that swapWithMark swaps with, or -1 if none is marked
+var markedPaneID = -1
+
+// markPane marks a pane, or unmarks it if it's already marked
+func markPane(pane *Pane) {
+	if markedPaneID == pane.id {
+		markedPaneID = -1
+	} else {
+		markedPaneID = pane.id
+	}
+}
+
+// swapWithMark swaps the selected pane with the marked one, like i3's `swap container with mark`
+func swapWithMark() error {
+	loc := findPane(markedPaneID)
+	if loc == nil {
+		return fmt.Errorf("no pane is marked")
+	}
+	swapWithPane(loc)
+	return nil
+}
+
+// rotateSplit moves each child of the split holding the focused container one place forward (or back), wrapping around.
+// Sizes are kept in place while the selection follows its child.
+func rotateSplit(reverse bool) {
+	unzoom()
+
+	parent, _ := getFocus().getParent()
+
+	n := len(parent.elements)
+	shift := 1
+	if reverse {
+		shift = n - 1
+	}
+
+	contents := make([]Container, n)
+	for idx, e := range parent.elements {
+		contents[(idx+shift)%n] = e.contents
+	}
+	for idx := range parent.elements {
+		parent.elements[idx].contents = contents[idx]
+	}
+	parent.selectionIdx = (parent.selectionIdx + shift) % n
+
+	root.refreshRenderRect()
+}
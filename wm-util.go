@@ -39,6 +39,19 @@ func getSelection() Path {
 	}
 }
 
+// getFocus is like getSelection, but leads to the container that has focus, which may be a split holding the selected pane
+func getFocus() Path {
+	path := getSelection()
+
+	// the workspace's own split can't be focused
+	depth := root.workspaces[root.selectionIdx].focusDepth
+	if max := len(path) - 2; depth > max {
+		depth = max
+	}
+
+	return path[:len(path)-depth]
+}
+
 func setSelection(path Path) {
 	root.selectionIdx = path[0]
 
@@ -90,6 +103,27 @@ func getPanes() []*Pane {
 	return getPanesOfSplit(root.workspaces[root.selectionIdx].contents)
 }
 
+func getPanesOfUniverse() []*Pane {
+	panes := []*Pane{}
+	for _, ws := range root.workspaces {
+		panes = append(panes, getPanesOfSplit(ws.contents)...)
+		panes = append(panes, ws.floating...)
+	}
+	panes = append(panes, root.scratchpad...)
+
+	return panes
+}
+
+// containsPane returns whether the pane is somewhere inside the split
+func containsPane(s *Split, pane *Pane) bool {
+	for _, p := range getPanesOfSplit(s) {
+		if p == pane {
+			return true
+		}
+	}
+	return false
+}
+
 func getPanesOfSplit(s *Split) []*Pane {
 	panes := []*Pane{}
 	for _, e := range s.elements {
@@ -104,6 +138,132 @@ func getPanesOfSplit(s *Split) []*Pane {
 	return panes
 }
 
+// A PaneLocation is where a pane is: tiled in a workspace, floating above one, or hidden in the scratchpad
+type PaneLocation struct {
+	pane *Pane
+
+	// path is the pane's path if it's tiled, or the path of its workspace if it's floating
+	path Path
+
+	// floatingIdx is the pane's index in its workspace's floating panes, or -1
+	floatingIdx int
+
+	// scratchpadIdx is the pane's index in the scratchpad, or -1
+	scratchpadIdx int
+}
+
+// findPane returns where the pane with the given id is, or nil if there is no such pane
+func findPane(id int) *PaneLocation {
+	if path := findTiledPane(id); path != nil {
+		return &PaneLocation{pane: path.getContainer().(*Pane), path: path, floatingIdx: -1, scratchpadIdx: -1}
+	}
+
+	for wsIdx, ws := range root.workspaces {
+		for idx, pane := range ws.floating {
+			if pane.id == id {
+				return &PaneLocation{pane: pane, path: Path{wsIdx}, floatingIdx: idx, scratchpadIdx: -1}
+			}
+		}
+	}
+
+	for idx, pane := range root.scratchpad {
+		if pane.id == id {
+			return &PaneLocation{pane: pane, floatingIdx: -1, scratchpadIdx: idx}
+		}
+	}
+
+	return nil
+}
+
+// findTiledPane returns the path to the tiled pane with the given id, or nil if there is none
+func findTiledPane(id int) Path {
+	for idx, ws := range root.workspaces {
+		if path := findPaneInSplit(ws.contents, id, Path{idx}); path != nil {
+			return path
+		}
+	}
+
+	return nil
+}
+
+func findPaneInSplit(s *Split, id int, path Path) Path {
+	for idx, e := range s.elements {
+		p := append(append(Path{}, path...), idx)
+		switch c := e.contents.(type) {
+		case *Split:
+			if found := findPaneInSplit(c, id, p); found != nil {
+				return found
+			}
+		case *Pane:
+			if c.id == id {
+				return p
+			}
+		}
+	}
+
+	return nil
+}
+
+// findPaneAtCoords returns the path to the visible pane at the given coordinates, or nil if there is none
+func findPaneAtCoords(x, y int) Path {
+	path := Path{root.selectionIdx}
+	s := root.workspaces[root.selectionIdx].contents
+
+	for {
+		found := false
+		for idx, n := range s.elements {
+			// the children of a tabbed or stacked split overlap, but only one is shown
+			if s.mode != Tiled && idx != s.selectionIdx {
+				continue
+			}
+
+			r := n.contents.getRenderRect()
+			if r.x <= x && x < r.x+r.w && r.y <= y && y < r.y+r.h {
+				path = append(path, idx)
+				switch c := n.contents.(type) {
+				case *Pane:
+					return path
+				case *Split:
+					s = c
+				}
+				found = true
+				break
+			}
+		}
+		if !found {
+			return nil
+		}
+	}
+}
+
+// paneAtCoords returns the pane whose contents are shown at the given coordinates, or nil if there is none
+func paneAtCoords(x, y int) *Pane {
+	ws := root.workspaces[root.selectionIdx]
+	inside := func(r Rect) bool {
+		return r.x <= x && x < r.x+r.w && r.y <= y && y < r.y+r.h
+	}
+
+	if ws.zoomed {
+		if pane := ws.selectedPane(); inside(pane.renderRect) {
+			return pane
+		}
+		return nil
+	}
+
+	if idx := findFloatingAtCoords(x, y); idx != -1 {
+		// the border doesn't count
+		if pane := ws.floating[idx]; inside(pane.renderRect) {
+			return pane
+		}
+		return nil
+	}
+
+	if path := findPaneAtCoords(x, y); path != nil {
+		return path.getContainer().(*Pane)
+	}
+	return nil
+}
+
 func (p Path) popContainer(idx int) Container {
 	s := p.getContainer().(*Split)
 
@@ -111,13 +271,20 @@ func (p Path) popContainer(idx int) Container {
 
 	s.elements = append(s.elements[:idx], s.elements[idx+1:]...)
 
+	// a split left empty, e.g. one made by splitNext, goes too
+	if len(s.elements) == 0 && len(p) > 1 {
+		_, parentPath := p.getParent()
+		parentPath.popContainer(p[len(p)-1])
+		return tmp.contents
+	}
+
 	// resize nodes
 	scaleFactor := float32(1.0 / (1.0 - tmp.size))
 	for i := range s.elements {
 		s.elements[i].size *= scaleFactor
 	}
 
-	if idx > len(s.elements)-1 {
+	if idx < s.selectionIdx || s.selectionIdx > len(s.elements)-1 {
 		s.selectionIdx--
 	}
 
@@ -126,8 +293,11 @@ func (p Path) popContainer(idx int) Container {
 		case *Pane:
 			parent, _ := p.getParent()
 			parent.elements[p[len(p)-1]].contents = val
+			// it may have been behind a tab
+			val.setPause(s.paused)
 		case *Split:
 			s.verticallyStacked = val.verticallyStacked
+			s.mode = val.mode
 			s.elements = val.elements
 			s.selectionIdx = val.selectionIdx
 		}
@@ -2,38 +2,188 @@ package main
 
 import (
 	"fmt"
+
+	"github.com/aaronjanse/3mux/render"
 )
 
 // A Workspace is a desktop
 type Workspace struct {
-	contents     *Split
-	doFullscreen bool
+	num      int
+	contents *Split
+	zoomed   bool
+
+	// focusDepth is how many levels above the selected pane the focus is, see focusParent
+	focusDepth int
+
+	// preset is the last layout applied by selectLayout, so nextLayout knows what comes next
+	preset Preset
+
+	// floating panes are drawn above the tiled ones, the last on top
+	floating []*Pane
+	// floatingFocused is set when the top floating pane has focus rather than the selected tiled pane
+	floatingFocused bool
+}
+
+func newWorkspace(num int, c Container) *Workspace {
+	return &Workspace{
+		num: num,
+		contents: &Split{
+			verticallyStacked: false,
+			selectionIdx:      0,
+			elements: []Node{
+				Node{
+					size:     1,
+					contents: c,
+				},
+			},
+		},
+		zoomed: false,
+	}
 }
 
 func (s *Workspace) serialize() string {
-	return fmt.Sprintf("Workspace(%s)", s.contents.serialize())
+	out := fmt.Sprintf("Workspace[%d](%s", s.num, s.contents.serialize())
+	for _, pane := range s.floating {
+		out += ", Floating " + pane.serialize()
+	}
+	return out + ")"
 }
 
 func (s *Workspace) setRenderRect(x, y, w, h int) {
-	if s.doFullscreen {
+	// floating panes hide whatever is drawn under them, including their borders
+	layers := []render.Rect{}
+	if !s.zoomed {
+		for _, pane := range s.floating {
+			pane.renderRect = clampFloating(pane.renderRect, Rect{x, y, w, h})
+			r := pane.renderRect
+			layers = append(layers, render.Rect{X: r.x - 1, Y: r.y - 1, W: r.w + 2, H: r.h + 2})
+		}
+	}
+
+	// the buffer chooser goes above everything
+	var chooser Rect
+	if bufferChooser != nil {
+		chooser = bufferChooserRect(Rect{x, y, w, h})
+		layers = append(layers, render.Rect{X: chooser.x - 1, Y: chooser.y - 1, W: chooser.w + 2, H: chooser.h + 2})
+	}
+	renderer.SetLayers(layers)
+
+	if s.zoomed {
 		getSelection().getContainer().setRenderRect(x, y, w, h)
 	} else {
 		s.contents.setRenderRect(x, y, w, h)
+
+		// a focused split is outlined as a whole
+		if s.focusDepth > 0 {
+			drawSelectionBorder(getFocus().getContainer().getRenderRect())
+		}
+
+		for idx, pane := range s.floating {
+			pane.vterm.Layer = idx + 1
+			r := pane.renderRect
+			pane.setRenderRect(r.x, r.y, r.w, r.h)
+		}
+	}
+
+	if bufferChooser != nil {
+		drawBufferChooser(chooser, len(layers))
 	}
 }
 
-func (s *Workspace) addPane() {
-	s.contents.addPane()
+// setZoom zooms in on the selected pane or back out, showing or hiding the other panes if the workspace is visible
+func (s *Workspace) setZoom(zoomed bool) {
+	s.zoomed = zoomed
+	if s == root.workspaces[root.selectionIdx] {
+		s.setPause(false)
+	}
+}
+
+// setPause hides or shows the workspace. A zoomed workspace only shows its selected pane
+func (s *Workspace) setPause(pause bool) {
+	s.contents.setPause(true)
+	if !pause {
+		if s.zoomed {
+			s.selectedPane().setPause(false)
+		} else {
+			s.contents.setPause(false)
+		}
+	}
+
+	for _, pane := range s.floating {
+		pane.setPause(pause || s.zoomed)
+	}
+}
+
+func (s *Workspace) selectedPane() *Pane {
+	var selection Container = s.contents
+	for {
+		switch val := selection.(type) {
+		case *Pane:
+			return val
+		case *Split:
+			selection = val.elements[val.selectionIdx].contents
+		default:
+			panic(fmt.Sprintf("Unexpected type %T", selection))
+		}
+	}
+}
+
+// focusedPane is the pane that gets keyboard input: the top floating pane if it has focus, otherwise the selected pane
+func (s *Workspace) focusedPane() *Pane {
+	if s.floatingFocused {
+		return s.floating[len(s.floating)-1]
+	}
+	return s.selectedPane()
+}
+
+// removeFloating takes a pane out of the floating panes, moving focus back to the tiled panes if it had it
+func (s *Workspace) removeFloating(idx int) *Pane {
+	pane := s.floating[idx]
+	if idx == len(s.floating)-1 {
+		s.floatingFocused = false
+	}
+	s.floating = append(s.floating[:idx], s.floating[idx+1:]...)
+	pane.vterm.Layer = 0
+	return pane
+}
+
+// refill tiles the top floating pane if there are no tiled panes left, returning false if the workspace is empty
+func (s *Workspace) refill() bool {
+	if len(s.contents.elements) > 0 {
+		return true
+	}
+	if len(s.floating) == 0 {
+		return false
+	}
+
+	pane := s.removeFloating(len(s.floating) - 1)
+	s.contents.elements = []Node{Node{size: 1, contents: pane}}
+	s.contents.selectionIdx = 0
+	return true
+}
+
+func (s *Workspace) addPane(dir string, args []string, env []string) {
+	s.contents.addPane(dir, args, env)
 }
 
 func (s *Workspace) selectAtCoords(x, y int) {
+	// the zoomed pane covers the others
+	if s.zoomed {
+		return
+	}
 	s.contents.selectAtCoords(x, y)
 }
 
 func (s *Workspace) updateSelection(selected bool) {
-	s.contents.updateSelection(selected)
+	s.contents.updateSelection(selected && !s.floatingFocused)
+	for idx, pane := range s.floating {
+		pane.UpdateSelection(selected && s.floatingFocused && idx == len(s.floating)-1)
+	}
 }
 
 func (s *Workspace) dragBorder(x1, y1, x2, y2 int) {
+	if s.zoomed {
+		return
+	}
 	s.contents.dragBorder(x1, y1, x2, y2)
 }